@@ -0,0 +1,90 @@
+package redis_mq
+
+import (
+	"context"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// Acker 手动确认模式下用于确认单条消息
+type Acker interface {
+	Ack(ctx context.Context) error
+}
+
+type ackerCtxKey struct{}
+
+// AckerFromContext 手动确认模式下, 在回调函数中获取当前消息的 Acker
+// Acker 可以在回调函数返回之后再使用, 此时需要传入新的 ctx
+func AckerFromContext(ctx context.Context) (Acker, bool) {
+	acker, ok := ctx.Value(ackerCtxKey{}).(Acker)
+	return acker, ok
+}
+
+func withAcker(ctx context.Context, acker Acker) context.Context {
+	return context.WithValue(ctx, ackerCtxKey{}, acker)
+}
+
+// msgAcker 绑定了消费者与消息的 Acker 实现
+type msgAcker struct {
+	consumer *Consumer
+	msg      *redis.MsgEntity
+}
+
+func (a *msgAcker) Ack(ctx context.Context) error {
+	return a.consumer.Ack(ctx, a.msg)
+}
+
+// AckPolicy 自动确认消息的时机
+type AckPolicy int
+
+const (
+	// AckAfterSuccess 回调函数执行成功后再 ack, 默认策略. 提供至少一次(at-least-once)投递:
+	// 失败或处理期间进程崩溃的消息会留在 pending 列表中被再次投递, 回调函数需要保证幂等
+	AckAfterSuccess AckPolicy = iota
+	// AckBeforeProcess 读取到消息后立即批量 ack, 再执行回调函数. 提供至多一次(at-most-once)投递:
+	// 回调函数失败或处理期间进程崩溃的消息不会重试, 也不会投递到死信队列, 适用于吞吐优先, 允许丢消息的场景
+	AckBeforeProcess
+)
+
+// ackOnReceive 消息是否在读取时就已经确认, 此时处理失败的消息不会被再次投递
+func (c *Consumer) ackOnReceive() bool {
+	return c.opts.noAck || c.opts.ackPolicy == AckBeforeProcess
+}
+
+// ackBeforeProcess AckBeforeProcess 策略下先批量 ack 读取到的消息, 返回需要继续处理的消息.
+// ack 失败时本批消息留在 pending 列表中, 暂不处理, 下一轮读取 pending 消息时再次尝试, 避免同一条消息被处理两次
+func (c *Consumer) ackBeforeProcess(ctx context.Context, msgs []*redis.MsgEntity) []*redis.MsgEntity {
+	if c.opts.noAck || c.opts.ackPolicy != AckBeforeProcess || len(msgs) == 0 {
+		return msgs
+	}
+
+	msgIDs := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		msgIDs = append(msgIDs, msg.MsgID)
+	}
+	acked, err := c.client.XAckBatch(ctx, c.topic, c.groupID, msgIDs...)
+	if err != nil {
+		c.opts.logger.ErrorFormat("msg ack before process failed, msg ids: %v, err: %v", msgIDs, err)
+		return nil
+	}
+	c.countAcked(msgIDs, acked)
+
+	return msgs
+}
+
+// countAcked 按照实际新确认的条数上报指标, 其余消息此前已经被确认过, 通常意味着消息被重复投递
+func (c *Consumer) countAcked(msgIDs []string, acked int64) {
+	if acked > 0 {
+		c.opts.metrics.IncAcked(c.topic, c.groupID, int(acked))
+	}
+	if duplicated := int64(len(msgIDs)) - acked; duplicated > 0 {
+		c.opts.logger.DebugFormat("msg already acked, topic: %s, group id: %s, duplicated: %d, msg ids: %v", c.topic, c.groupID, duplicated, msgIDs)
+	}
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
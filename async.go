@@ -0,0 +1,141 @@
+package redis_mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// asyncFlushTimeout 异步发送单批写入的超时时长
+const asyncFlushTimeout = 5 * time.Second
+
+// ErrAsyncBufferFull 异步发送缓冲区已满, 消息被丢弃
+var ErrAsyncBufferFull = errors.New("async send buffer is full")
+
+// AsyncDropHook 异步发送的消息被丢弃时触发, err 为 ErrAsyncBufferFull 或写入失败的原因
+type AsyncDropHook func(topic, key, val string, err error)
+
+// asyncMsg 缓冲区中的消息, val 为原始值, entry 中为压缩后实际写入的值
+type asyncMsg struct {
+	val   string
+	entry redis.XAddEntry
+}
+
+// SendAsync 将消息放入缓冲区后立即返回, 由后台协程攒批后通过 pipeline 写入, 攒批策略由 WithAsyncBatch 指定.
+// 缓冲区已满或写入失败的消息会被丢弃并触发 WithOnAsyncDropped 设置的 hook, 不会重试; 不支持幂等发送与限流.
+// ctx 只用于读取链路追踪与 WithProducerContextFields 的值. Close 时会写入缓冲区中剩余的消息
+func (p *Producer) SendAsync(ctx context.Context, topic, key, val string) error {
+	if topic == "" {
+		return fmt.Errorf("redis async msg: %w", redis.ErrEmptyTopic)
+	}
+	if err := p.checkMsgSize(key, val); err != nil {
+		return err
+	}
+
+	if err := p.beginSend(); err != nil {
+		return err
+	}
+	defer p.sending.Done()
+
+	sendVal, xAddArgs, err := p.xAddArgs(ctx, val, sendOptions{})
+	if err != nil {
+		return err
+	}
+
+	p.asyncOnce.Do(p.startAsync)
+	msg := &asyncMsg{
+		val:   val,
+		entry: redis.XAddEntry{Topic: topic, Key: key, Val: sendVal, Args: xAddArgs},
+	}
+	select {
+	case p.asyncCh <- msg:
+	default:
+		p.opts.onAsyncDropped(topic, key, val, ErrAsyncBufferFull)
+	}
+
+	return nil
+}
+
+func (p *Producer) startAsync() {
+	p.asyncCh = make(chan *asyncMsg, p.opts.asyncBufferSize)
+	p.asyncDone = make(chan struct{})
+	go p.runAsync()
+}
+
+// runAsync 攒批写入缓冲区中的消息, 缓冲区关闭后写入剩余消息并退出
+func (p *Producer) runAsync() {
+	defer close(p.asyncDone)
+
+	ticker := time.NewTicker(p.opts.asyncFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*asyncMsg, 0, p.opts.asyncBatchSize)
+	for {
+		select {
+		case msg, ok := <-p.asyncCh:
+			if !ok {
+				p.flushAsync(batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) < p.opts.asyncBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		p.flushAsync(batch)
+		batch = batch[:0]
+	}
+}
+
+// flushAsync 通过 pipeline 写入一批消息, 失败的消息触发丢弃 hook
+func (p *Producer) flushAsync(batch []*asyncMsg) {
+	if len(batch) == 0 {
+		return
+	}
+
+	entries := make([]redis.XAddEntry, 0, len(batch))
+	for _, msg := range batch {
+		entries = append(entries, msg.entry)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), asyncFlushTimeout)
+	defer cancel()
+
+	results, err := p.client.XAddBatch(ctx, entries)
+	for i, msg := range batch {
+		msgErr := err
+		if msgErr == nil {
+			msgErr = results[i].Err
+		}
+		if msgErr != nil {
+			p.opts.onAsyncDropped(msg.entry.Topic, msg.entry.Key, msg.val, msgErr)
+			continue
+		}
+		p.opts.metrics.IncProduced(msg.entry.Topic)
+	}
+}
+
+// closeAsync 关闭缓冲区并等待剩余消息写入完成, ctx 结束时不再等待. 重复调用时继续等待
+func (p *Producer) closeAsync(ctx context.Context) error {
+	if p.asyncCh == nil {
+		return nil
+	}
+
+	p.asyncCloseOnce.Do(func() {
+		close(p.asyncCh)
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.asyncDone:
+		return nil
+	}
+}
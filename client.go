@@ -0,0 +1,73 @@
+package redis_mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// ProducerClient 生产者依赖的 redis 操作, *redis.Client 实现了该接口.
+// 测试时可以使用 mqtest.FakeClient 代替, 无需启动 redis
+type ProducerClient interface {
+	XAddMsgWithArgs(ctx context.Context, topic string, xAddArgs redis.XAddArgs, key, val string) (string, error)
+	XAddMsgWithStats(ctx context.Context, topic string, xAddArgs redis.XAddArgs, key, val string) (*redis.XAddResult, error)
+	XAddBatch(ctx context.Context, entries []redis.XAddEntry) ([]redis.XAddEntryResult, error)
+	Get(ctx context.Context, key string) (string, error)
+	SetEX(ctx context.Context, key, value string, expireSeconds int64) (int64, error)
+	SetNEX(ctx context.Context, key, value string, expireSeconds int64) (int64, error)
+	Del(ctx context.Context, key string) error
+	ZAddWithList(ctx context.Context, key string, score int64, member, listKey string, items []string) error
+}
+
+// DeadLetterReplayClient 重放死信消息依赖的 redis 操作, Producer.ReplayDeadLetter 要求 ProducerClient 同时实现该接口,
+// *redis.Client 实现了该接口
+type DeadLetterReplayClient interface {
+	XRange(ctx context.Context, topic, start, end string, count int) ([]*redis.MsgEntity, error)
+	XDel(ctx context.Context, topic string, msgIDs ...string) (int64, error)
+}
+
+// ConsumerClient 消费者依赖的 redis 操作, *redis.Client 实现了该接口
+type ConsumerClient interface {
+	XGroupCreateMkStream(ctx context.Context, topic, group, startID string) (string, error)
+	XReadGroupNewMsgWithArgs(ctx context.Context, groupID, consumerID, topic string, args redis.XReadGroupArgs) ([]*redis.MsgEntity, error)
+	XReadGroupPendingMsg(ctx context.Context, groupID, consumerID, topic, startID string, args redis.XReadGroupArgs) ([]*redis.MsgEntity, error)
+	XPendingExt(ctx context.Context, topic, groupID, start, end string, count int, consumer string) ([]*redis.PendingEntry, error)
+	XPendingIdle(ctx context.Context, topic, groupID string, minIdle time.Duration, count int, consumer string) ([]*redis.PendingEntry, error)
+	XClaim(ctx context.Context, topic, groupID, consumer string, minIdle time.Duration, msgIDs ...string) ([]*redis.MsgEntity, error)
+	XAck(ctx context.Context, topic, groupID, msgID string) error
+	XAckWithResult(ctx context.Context, topic, groupID, msgID string) (bool, error)
+	XAckBatch(ctx context.Context, topic, groupID string, msgIDs ...string) (int64, error)
+	XRange(ctx context.Context, topic, start, end string, count int) ([]*redis.MsgEntity, error)
+	// Stats 连接池状态, 用于连接池耗尽时的日志与回调
+	Stats() redis.PoolStats
+}
+
+// KeyspaceNotifyClient 支持 keyspace 通知的客户端, 开启 WithKeyspaceNotify 时 ConsumerClient 需要同时实现该接口, *redis.Client 实现了该接口
+type KeyspaceNotifyClient interface {
+	KeyspaceEventsEnabled(ctx context.Context, classes string) (bool, error)
+	KeyspaceChannel(key string) string
+	Subscribe(ctx context.Context, channels ...string) (*redis.Subscription, error)
+}
+
+// TailClient 广播消费者依赖的 redis 操作, *redis.Client 实现了该接口
+type TailClient interface {
+	XRead(ctx context.Context, topic, fromID string, count, blockMillis int) ([]*redis.MsgEntity, error)
+	XTail(ctx context.Context, topic string, n int) ([]*redis.MsgEntity, error)
+}
+
+// DelayedPollerClient 延迟消息轮询器依赖的 redis 操作, *redis.Client 实现了该接口
+type DelayedPollerClient interface {
+	ZRangeByScore(ctx context.Context, key, min, max string, count int) ([]string, error)
+	EvalScript(ctx context.Context, s *redis.Script, keys []string, args []interface{}) (interface{}, error)
+	FieldNames() redis.FieldNames
+}
+
+var (
+	_ TailClient             = (*redis.Client)(nil)
+	_ ProducerClient         = (*redis.Client)(nil)
+	_ ConsumerClient         = (*redis.Client)(nil)
+	_ KeyspaceNotifyClient   = (*redis.Client)(nil)
+	_ DeadLetterReplayClient = (*redis.Client)(nil)
+	_ DelayedPollerClient    = (*redis.Client)(nil)
+)
@@ -0,0 +1,20 @@
+package redis_mq
+
+import "time"
+
+// clock 时间来源, 便于在测试中驱动退避, 空闲时长等与时间相关的逻辑
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock 基于系统时间的 clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,105 @@
+package redis_mq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// compressionHeader 标记消息 val 压缩算法的附加字段, 没有该字段的消息视为未压缩
+const compressionHeader = "x-compression"
+
+// Compressor 消息压缩算法, 使用方可以自行实现以接入 snappy, zstd 等算法
+type Compressor interface {
+	// Name 算法名称, 会写入消息的附加字段中, 消费方据此选择解压算法
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor gzip 压缩算法
+type GzipCompressor struct {
+	// Level 压缩级别, 为 0 时使用 gzip.DefaultCompression
+	Level int
+}
+
+func (g GzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (g GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (g GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	return io.ReadAll(r)
+}
+
+// compress 压缩消息 val, 并在附加字段中标记压缩算法
+func (p *Producer) compress(val string, headers map[string]string) (string, map[string]string, error) {
+	if p.opts.compressor == nil {
+		return val, headers, nil
+	}
+
+	data, err := p.opts.compressor.Compress([]byte(val))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[compressionHeader] = p.opts.compressor.Name()
+	return string(data), headers, nil
+}
+
+// decompress 根据附加字段中标记的压缩算法解压消息 val, 未压缩的消息保持不变
+func (c *Consumer) decompress(msg *redis.MsgEntity) error {
+	name, ok := msg.Headers[compressionHeader]
+	if !ok {
+		return nil
+	}
+
+	compressor, ok := c.opts.compressors[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown compression: %s", ErrPoisonMsg, name)
+	}
+
+	data, err := compressor.Decompress([]byte(msg.Val))
+	if err != nil {
+		return fmt.Errorf("%w: decompress msg failed: %v", ErrPoisonMsg, err)
+	}
+
+	// 解压后移除标记, 避免重试时重复解压
+	msg.Val = string(data)
+	delete(msg.Headers, compressionHeader)
+	return nil
+}
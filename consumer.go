@@ -3,25 +3,61 @@ package redis_mq
 import (
 	"context"
 	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
 
-	"github.com/bing-bing-student/redis-mq/log"
 	"github.com/bing-bing-student/redis-mq/redis"
 )
 
 // MsgCallback 接收到消息后执行的回调函数
 type MsgCallback func(ctx context.Context, msg *redis.MsgEntity) error
 
+// ErrPoisonMsg 回调函数返回的 error 包装了该错误时, 消息不再重试, 而是直接投递到死信队列(未设置 WithRetryDecider 时)
+var ErrPoisonMsg = errors.New("poison message")
+
+// ErrCallbackPanic 回调函数 panic 时, 传给 ResultHook 的 error 包装了该错误
+var ErrCallbackPanic = errors.New("callback panic")
+
+// ResultHook 每次执行回调函数后触发, err 为回调函数的返回值, attempt 为该消息当前是第几次被处理
+type ResultHook func(ctx context.Context, msg *redis.MsgEntity, err error, attempt int)
+
+// PoolExhaustedHook 连接池耗尽时触发, stats 为当时连接池的统计信息
+type PoolExhaustedHook func(ctx context.Context, stats redis.PoolStats)
+
+// StoppedHook consumer 因运行期错误自行停止时触发, 例如消费者组被删除且未开启 WithAutoCreateGroup
+type StoppedHook func(topic, groupID, consumerID string, err error)
+
+// pendingDetailLimit 单次查询 pending 消息的最大条数
+const pendingDetailLimit = 1000
+
+// ackFlushTimeout consumer 停止时补充 ack 的超时时长
+const ackFlushTimeout = time.Second
+
+// panicRestartBackoff 消费循环 panic 后重新启动前的等待时长
+const panicRestartBackoff = time.Second
+
 // Consumer 消费者
 type Consumer struct {
 	// consumer 生命周期管理
-	ctx  context.Context
-	stop context.CancelFunc
+	ctx      context.Context
+	stop     context.CancelFunc
+	stopOnce sync.Once
+	// 消费循环是否已启动, 以及消费循环退出后关闭的 channel
+	started atomic.Bool
+	done    chan struct{}
 
 	// 接收到 msg 时执行的回调函数，由使用方定义
 	callbackFunc MsgCallback
+	// channel 模式下投递消息的 channel, 与 callbackFunc 二选一
+	msgCh chan *redis.MsgEntity
 
 	// redis 客户端，基于 redis 实现 message queue
-	client *redis.Client
+	client ConsumerClient
 
 	// 消费的 topic
 	topic string
@@ -30,17 +66,77 @@ type Consumer struct {
 	// 当前节点的消费者 id
 	consumerID string
 
-	// 各消息累计失败次数
-	failureCounts map[redis.MsgEntity]int
+	// 读取 pending 消息的游标, 即上一轮读取到的最后一条 pending 消息 id
+	pendingCursor string
+
+	// 各消息累计失败次数, key 为消息 id
+	failures *failureStore
+	// 回调函数已成功执行但尚未 ack 成功的消息 id
+	processed map[string]struct{}
+
+	// 已交给使用方但尚未 ack 的消息 id, 仅在手动确认模式下记录
+	inFlightMu sync.Mutex
+	inFlight   map[string]struct{}
+	// 在途消息被 ack 时通知消费循环
+	inFlightCh chan struct{}
+
+	// 开启 WithAdaptiveBlock 时下一轮阻塞读取的时长, 只在消费循环中访问
+	adaptiveBlock time.Duration
+	// 上一次检查 pending 消息空闲时长的时间, 只在消费循环中访问
+	lastPendingAgeCheck time.Time
+
+	// 消费循环是否正在运行
+	healthy atomic.Bool
+
+	// keyspace 通知是否可用, 可用时以非阻塞方式读取新消息, 由 wakeCh 唤醒
+	notifyActive atomic.Bool
+	wakeCh       chan struct{}
+
+	// 暂停状态, 暂停期间 resumeCh 在恢复时被关闭
+	pauseMu  sync.Mutex
+	paused   bool
+	resumeCh chan struct{}
 
 	// 一些用户自定义的配置
 	opts *ConsumerOptions
 }
 
-func NewConsumer(client *redis.Client, topic, groupID, consumerID string, callbackFunc MsgCallback, opts ...ConsumerOption) (*Consumer, error) {
+// NewConsumer 新建消费者并开始消费, consumerID 为空时通过 GenerateConsumerID 自动生成
+func NewConsumer(client ConsumerClient, topic, groupID, consumerID string, callbackFunc MsgCallback, opts ...ConsumerOption) (*Consumer, error) {
+	c := newConsumer(client, topic, groupID, consumerID, callbackFunc)
+	if err := c.init(opts...); err != nil {
+		return nil, err
+	}
+
+	c.start()
+	return c, nil
+}
+
+// NewChannelConsumer 新建以 channel 方式接收消息的消费者, 通过 Messages 读取消息, 处理完成后需要调用 Ack 进行确认.
+// 已投递但未确认的消息会停留在 pending 列表中, 建议配合 WithPendingMinIdle 使用以避免重复投递
+func NewChannelConsumer(client ConsumerClient, topic, groupID, consumerID string, opts ...ConsumerOption) (*Consumer, error) {
+	c := newConsumer(client, topic, groupID, consumerID, nil)
+	c.msgCh = make(chan *redis.MsgEntity)
+	c.callbackFunc = c.deliverToChannel
+	if err := c.init(opts...); err != nil {
+		return nil, err
+	}
+
+	// channel 模式下由使用方负责 ack
+	c.opts.manualAck = true
+
+	c.start()
+	return c, nil
+}
+
+func newConsumer(client ConsumerClient, topic, groupID, consumerID string, callbackFunc MsgCallback) *Consumer {
+	// 未指定 consumerID 时自动生成, 避免多个进程使用相同的 consumerID
+	if consumerID == "" {
+		consumerID = GenerateConsumerID(groupID)
+	}
 
 	ctx, stop := context.WithCancel(context.Background())
-	c := Consumer{
+	return &Consumer{
 		client:       client,
 		ctx:          ctx,
 		stop:         stop,
@@ -49,13 +145,23 @@ func NewConsumer(client *redis.Client, topic, groupID, consumerID string, callba
 		groupID:      groupID,
 		consumerID:   consumerID,
 
+		pendingCursor: "0-0",
+		done:          make(chan struct{}),
+
 		opts: &ConsumerOptions{},
 
-		failureCounts: make(map[redis.MsgEntity]int),
+		failures:   newFailureStore(),
+		processed:  make(map[string]struct{}),
+		inFlight:   make(map[string]struct{}),
+		inFlightCh: make(chan struct{}, 1),
 	}
+}
 
+// init 校验参数并加载配置
+func (c *Consumer) init(opts ...ConsumerOption) error {
 	if err := c.checkParam(); err != nil {
-		return nil, err
+		c.stop()
+		return err
 	}
 
 	for _, opt := range opts {
@@ -63,9 +169,20 @@ func NewConsumer(client *redis.Client, topic, groupID, consumerID string, callba
 	}
 
 	repairConsumer(c.opts)
+	c.adaptiveBlock = c.opts.adaptiveBlockMin
 
-	go c.run()
-	return &c, nil
+	if c.opts.autoCreateGroup {
+		if _, err := c.client.XGroupCreateMkStream(c.ctx, c.topic, c.groupID, c.opts.groupStartID); err != nil {
+			c.stop()
+			return err
+		}
+	}
+
+	if c.opts.keyspaceNotify {
+		c.startKeyspaceNotify()
+	}
+
+	return nil
 }
 
 func (c *Consumer) checkParam() error {
@@ -84,9 +201,170 @@ func (c *Consumer) checkParam() error {
 	return nil
 }
 
-// Stop 停止 consumer
+// Stop 停止 consumer 并等待消费循环退出、缓冲的 ack 提交完成, 可以重复或并发调用.
+// 不能在回调函数中调用, 否则会一直阻塞
 func (c *Consumer) Stop() {
-	c.stop()
+	c.cancel()
+	c.wait()
+}
+
+// cancel 通知消费循环退出, 不等待
+func (c *Consumer) cancel() {
+	c.stopOnce.Do(c.stop)
+}
+
+// wait 等待消费循环退出, 消费循环未启动时直接返回
+func (c *Consumer) wait() {
+	if c.started.Load() {
+		<-c.done
+	}
+}
+
+// Topic 返回消费的 topic
+func (c *Consumer) Topic() string {
+	return c.topic
+}
+
+// Group 返回所属的消费者组
+func (c *Consumer) Group() string {
+	return c.groupID
+}
+
+// ConsumerID 返回消费者 id, 未指定时为自动生成的 id
+func (c *Consumer) ConsumerID() string {
+	return c.consumerID
+}
+
+// Pause 暂停拉取和处理消息, 消费者组注册信息与运行协程保持不变, 暂停期间仍然可以 Stop
+func (c *Consumer) Pause() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.resumeCh = make(chan struct{})
+}
+
+// Resume 恢复拉取和处理消息
+func (c *Consumer) Resume() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	close(c.resumeCh)
+}
+
+// Paused 是否处于暂停状态
+func (c *Consumer) Paused() bool {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+
+	return c.paused
+}
+
+// waitResume 处于暂停状态时阻塞直到恢复, consumer 被停止时返回 false
+func (c *Consumer) waitResume() bool {
+	c.pauseMu.Lock()
+	resumeCh := c.resumeCh
+	paused := c.paused
+	c.pauseMu.Unlock()
+
+	if !paused {
+		return true
+	}
+
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-resumeCh:
+		return true
+	}
+}
+
+// Messages channel 模式下返回接收消息的 channel, consumer 停止后 channel 会被关闭; 回调模式下返回 nil
+func (c *Consumer) Messages() <-chan *redis.MsgEntity {
+	return c.msgCh
+}
+
+// Ack 确认消息, 用于 channel 模式或手动确认模式
+func (c *Consumer) Ack(ctx context.Context, msg *redis.MsgEntity) error {
+	if msg == nil {
+		return errors.New("msg can't be empty")
+	}
+
+	acked, err := c.client.XAckWithResult(ctx, c.topic, c.groupID, msg.MsgID)
+	if err != nil {
+		return err
+	}
+
+	c.countAcked([]string{msg.MsgID}, boolToInt64(acked))
+	c.releaseInFlight(msg.MsgID)
+	return nil
+}
+
+// Decode 将消息 val 按照 WithConsumerCodec 指定的方式反序列化到 v 中, 失败时返回的 error 包装了 ErrPoisonMsg
+func (c *Consumer) Decode(msg *redis.MsgEntity, v interface{}) error {
+	if err := c.opts.codec.Unmarshal([]byte(msg.Val), v); err != nil {
+		return fmt.Errorf("%w: decode msg failed: %v", ErrPoisonMsg, err)
+	}
+	return nil
+}
+
+// deliverToChannel channel 模式下的回调函数, 将消息投递到 channel 中直到被读取或 consumer 停止
+func (c *Consumer) deliverToChannel(_ context.Context, msg *redis.MsgEntity) error {
+	select {
+	case c.msgCh <- msg:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// start 在新的 goroutine 中启动消费循环
+func (c *Consumer) start() {
+	c.started.Store(true)
+	go c.supervise()
+}
+
+// supervise 运行消费循环, 循环因 panic 退出时记录堆栈并重新启动, 直到 consumer 停止
+func (c *Consumer) supervise() {
+	defer close(c.done)
+	if c.msgCh != nil {
+		defer close(c.msgCh)
+	}
+	defer c.flushAcks()
+
+	for c.ctx.Err() == nil {
+		c.runSafely()
+	}
+}
+
+// runSafely 运行消费循环并捕获 panic, 运行期间 Healthy 返回 true
+func (c *Consumer) runSafely() {
+	c.healthy.Store(true)
+	defer func() {
+		c.healthy.Store(false)
+		if r := recover(); r != nil {
+			c.opts.logger.ErrorFormat("consumer loop panic, topic: %s, group id: %s, panic: %v, stack: %s", c.topic, c.groupID, r, debug.Stack())
+			// 持续 panic 时避免空转
+			select {
+			case <-c.ctx.Done():
+			case <-c.opts.clock.After(panicRestartBackoff):
+			}
+		}
+	}()
+
+	c.run()
+}
+
+// Healthy 消费循环是否正在运行, consumer 停止或者循环 panic 后等待重启期间返回 false, 可用于存活探针
+func (c *Consumer) Healthy() bool {
+	return c.healthy.Load()
 }
 
 // 运行消费者
@@ -98,87 +376,535 @@ func (c *Consumer) run() {
 		default:
 		}
 
+		if !c.waitResume() || !c.waitInFlight() {
+			return
+		}
+
 		// 新消息接收处理
 		msg, err := c.receive()
+		if errors.Is(err, redis.ErrNoGroup) {
+			if !c.recoverNoGroup() {
+				return
+			}
+			continue
+		}
 		if err != nil {
-			log.ErrorContextFormat(c.ctx, "receive msg failed, err: %v", err)
+			c.handleReceiveErr("receive msg failed", err)
 			continue
 		}
 
-		ctx, _ := context.WithTimeout(c.ctx, c.opts.handleMsgTimeout)
-		c.handlerMsg(ctx, msg)
+		ctx, cancel := context.WithTimeout(c.ctx, c.opts.handleMsgTimeout)
+		c.handlerMsg(ctx, c.ackBeforeProcess(ctx, msg))
+		cancel()
+
+		// 空闲过久的 pending 消息直接投递死信队列
+		c.expireStalePending()
 
 		// 死信队列投递
-		ctx, _ = context.WithTimeout(c.ctx, c.opts.deadLetterDeliverTimeout)
+		ctx, cancel = context.WithTimeout(c.ctx, c.opts.deadLetterDeliverTimeout)
 		c.deliverDeadLetter(ctx)
+		cancel()
+
+		// NOACK 模式下没有 pending 消息
+		if c.opts.noAck {
+			continue
+		}
 
 		// pending 消息接收处理
 		pendingMsg, err := c.receivePending()
+		if errors.Is(err, redis.ErrNoGroup) {
+			if !c.recoverNoGroup() {
+				return
+			}
+			continue
+		}
 		if err != nil {
-			log.ErrorContextFormat(c.ctx, "pending msg received failed, err: %v", err)
+			c.handleReceiveErr("pending msg received failed", err)
 			continue
 		}
 
-		ctx, _ = context.WithTimeout(c.ctx, c.opts.handleMsgTimeout)
-		c.handlerMsg(ctx, pendingMsg)
+		ctx, cancel = context.WithTimeout(c.ctx, c.opts.handleMsgTimeout)
+		c.handlerMsg(ctx, c.ackBeforeProcess(ctx, pendingMsg))
+		cancel()
+	}
+}
+
+// blockMilliseconds 返回 XREADGROUP BLOCK 的毫秒数, 0 表示无限阻塞, -1 表示 keyspace 通知可用时不阻塞.
+// 阻塞使用 consumer 自身的 ctx, Stop 时会中断阻塞中的读取
+func (c *Consumer) blockMilliseconds() int {
+	if c.notifyActive.Load() {
+		return -1
+	}
+	if c.opts.infiniteBlock {
+		return 0
+	}
+
+	timeout := c.opts.receiveTimeout
+	if c.opts.adaptiveBlockMax > 0 {
+		timeout = c.adaptiveBlock
+	}
+
+	// 不足 1ms 的超时会被截断为 0, 需要避免误变为无限阻塞
+	if ms := int(jitter(timeout, c.opts.backoffJitter).Milliseconds()); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+// adjustBlock 开启 WithAdaptiveBlock 时调整下一轮的阻塞时长, 读取到消息时恢复为下限, 没有消息时翻倍直到上限
+func (c *Consumer) adjustBlock(received bool) {
+	if c.opts.adaptiveBlockMax <= 0 {
+		return
+	}
+
+	if received {
+		c.adaptiveBlock = c.opts.adaptiveBlockMin
+		return
+	}
+
+	c.adaptiveBlock *= 2
+	if c.adaptiveBlock > c.opts.adaptiveBlockMax {
+		c.adaptiveBlock = c.opts.adaptiveBlockMax
+	}
+}
+
+// invalidMsgHandler 开启 WithSkipInvalidMsg 时返回跳过格式错误消息的回调, 否则返回 nil.
+// 格式错误的消息无法被处理, 记录日志后直接 ack, 避免留在 pending 列表中反复被读取
+func (c *Consumer) invalidMsgHandler() func(err *redis.MsgFormatError) {
+	if !c.opts.skipInvalidMsg {
+		return nil
+	}
+
+	return func(err *redis.MsgFormatError) {
+		c.opts.logger.ErrorFormat("skip invalid msg, err: %v", err)
+		if err.MsgID == "" || c.opts.noAck {
+			return
+		}
+		if ackErr := c.client.XAck(c.ctx, c.topic, c.groupID, err.MsgID); ackErr != nil {
+			c.opts.logger.ErrorFormat("invalid msg ack failed, msg id: %s, err: %v", err.MsgID, ackErr)
+		}
+	}
+}
+
+// recoverNoGroup 消费者组不存在时, 开启了 WithAutoCreateGroup 则重新创建, 否则停止 consumer, 避免无限重试.
+// 返回 consumer 是否可以继续运行
+func (c *Consumer) recoverNoGroup() bool {
+	if !c.opts.autoCreateGroup {
+		c.opts.logger.ErrorFormat("consumer group not found, consumer stopped, topic: %s, group id: %s", c.topic, c.groupID)
+		c.cancel()
+		c.opts.onStopped(c.topic, c.groupID, c.consumerID, redis.ErrNoGroup)
+		return false
+	}
+
+	if _, err := c.client.XGroupCreateMkStream(c.ctx, c.topic, c.groupID, c.opts.groupStartID); err != nil {
+		c.opts.logger.ErrorFormat("recreate consumer group failed, topic: %s, group id: %s, err: %v", c.topic, c.groupID, err)
+	}
+	return true
+}
+
+// handleReceiveErr 处理接收消息失败. 连接池耗尽时立即重试只会加剧争抢, 因此触发 hook 并退避一段时间
+func (c *Consumer) handleReceiveErr(desc string, err error) {
+	// Stop 取消了阻塞中的读取, 属于正常退出
+	if c.ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if !redis.IsPoolExhaustedErr(err) {
+		// 连接断开等临时错误会在下一轮自动恢复, 降级为 warn
+		if redis.IsTransientErr(err) {
+			c.opts.logger.WarnFormat("%s, err: %v", desc, err)
+		} else {
+			c.opts.logger.ErrorFormat("%s, err: %v", desc, err)
+		}
+		return
+	}
+
+	stats := c.client.Stats()
+	c.opts.logger.WarnFormat("%s, redis pool exhausted, active: %d, idle: %d, err: %v", desc, stats.ActiveCount, stats.IdleCount, err)
+	c.opts.onPoolExhausted(c.ctx, stats)
+
+	select {
+	case <-c.ctx.Done():
+	case <-c.opts.clock.After(jitter(c.opts.poolExhaustedBackoff, c.opts.backoffJitter)):
 	}
 }
 
 func (c *Consumer) receive() ([]*redis.MsgEntity, error) {
-	msg, err := c.client.XReadGroupNewMsg(c.ctx, c.groupID, c.consumerID, c.topic, int(c.opts.receiveTimeout.Milliseconds()))
+	block := c.blockMilliseconds()
+	msg, err := c.client.XReadGroupNewMsgWithArgs(c.ctx, c.groupID, c.consumerID, c.topic, redis.XReadGroupArgs{
+		Count:        c.readCount(),
+		Block:        block,
+		NoAck:        c.opts.noAck,
+		OnInvalidMsg: c.invalidMsgHandler(),
+	})
 	if err != nil && !errors.Is(err, redis.ErrNoMsg) {
 		return nil, err
 	}
 
+	// 非阻塞读取没有新消息时, 等待 keyspace 通知唤醒
+	if len(msg) == 0 && block < 0 {
+		c.waitWake()
+	}
+	if block > 0 {
+		c.adjustBlock(len(msg) > 0)
+	}
+
+	// 新消息首次投递
+	for _, m := range msg {
+		m.DeliveryCount = 1
+	}
+
 	return msg, nil
 }
 
 func (c *Consumer) receivePending() ([]*redis.MsgEntity, error) {
-	pendingMsg, err := c.client.XReadGroupOldMsg(c.ctx, c.groupID, c.consumerID, c.topic)
+	if c.opts.pendingMinIdle > 0 {
+		return c.claimIdlePending()
+	}
+
+	// 游标之后已经没有 pending 消息时, 从头开始读取
+	pendingMsg, err := c.readPendingFromCursor()
+	if err == nil && len(pendingMsg) == 0 && c.pendingCursor != "0-0" {
+		c.pendingCursor = "0-0"
+		pendingMsg, err = c.readPendingFromCursor()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pendingMsg) > 0 {
+		c.pendingCursor = pendingMsg[len(pendingMsg)-1].MsgID
+	}
+
+	return pendingMsg, nil
+}
+
+// readPendingFromCursor 从 pendingCursor 之后读取一批 pending 消息
+func (c *Consumer) readPendingFromCursor() ([]*redis.MsgEntity, error) {
+	// XREADGROUP 读取 pending 消息时会重置空闲时长, 因此需要在读取之前查询 pending 详情
+	details := c.pendingDetails(c.pendingCursor)
+
+	pendingMsg, err := c.client.XReadGroupPendingMsg(c.ctx, c.groupID, c.consumerID, c.topic, c.pendingCursor, redis.XReadGroupArgs{
+		Count:        c.pendingReadCount(),
+		OnInvalidMsg: c.invalidMsgHandler(),
+	})
 	if err != nil && !errors.Is(err, redis.ErrNoMsg) {
 		return nil, err
 	}
 
+	for _, msg := range pendingMsg {
+		// pending 消息至少已经投递过一次, 查询详情失败时同样视为再次投递
+		msg.Redelivered = true
+		if detail, ok := details[msg.MsgID]; ok {
+			// 本次读取会使投递次数再加一
+			msg.DeliveryCount = detail.DeliveryCount + 1
+			msg.IdleTime = detail.IdleTime
+		}
+	}
+
 	return pendingMsg, nil
 }
 
+// pendingReadCount 返回单次读取 pending 消息的条数, 不超过 pendingDetailLimit, 保证每条消息都能查到投递次数与空闲时长
+func (c *Consumer) pendingReadCount() int {
+	if c.opts.pendingBatchSize <= 0 || c.opts.pendingBatchSize > pendingDetailLimit {
+		return pendingDetailLimit
+	}
+	return c.opts.pendingBatchSize
+}
+
+// claimIdlePending 认领消费者组中空闲时长超过阈值的 pending 消息, 正在被其他消费者处理的消息不会被重复处理
+func (c *Consumer) claimIdlePending() ([]*redis.MsgEntity, error) {
+	entries, err := c.client.XPendingIdle(c.ctx, c.topic, c.groupID, c.opts.pendingMinIdle, pendingDetailLimit, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	details := make(map[string]*redis.PendingEntry, len(entries))
+	msgIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		details[entry.MsgID] = entry
+		msgIDs = append(msgIDs, entry.MsgID)
+	}
+
+	// XCLAIM 会再次校验空闲时长, 避免与其他消费者重复认领
+	claimedMsg, err := c.client.XClaim(c.ctx, c.topic, c.groupID, c.consumerID, c.opts.pendingMinIdle, msgIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range claimedMsg {
+		msg.Redelivered = true
+		if detail, ok := details[msg.MsgID]; ok {
+			msg.DeliveryCount = detail.DeliveryCount + 1
+			msg.IdleTime = detail.IdleTime
+		}
+	}
+
+	return claimedMsg, nil
+}
+
+// pendingDetails 通过 XPENDING 查询当前消费者从 start 开始的 pending 消息的投递次数与空闲时长, 查询失败时不影响消息处理
+func (c *Consumer) pendingDetails(start string) map[string]*redis.PendingEntry {
+	entries, err := c.client.XPendingExt(c.ctx, c.topic, c.groupID, start, "+", pendingDetailLimit, c.consumerID)
+	if err != nil {
+		c.opts.logger.WarnFormat("query pending msg detail failed, err: %v", err)
+		return nil
+	}
+
+	details := make(map[string]*redis.PendingEntry, len(entries))
+	for _, entry := range entries {
+		details[entry.MsgID] = entry
+	}
+
+	return details
+}
+
 func (c *Consumer) handlerMsg(ctx context.Context, messages []*redis.MsgEntity) {
+	defer func() {
+		c.opts.metrics.SetFailureCount(c.topic, c.groupID, c.failures.len())
+	}()
+
+	var successMsgs []*redis.MsgEntity
 	for _, msg := range messages {
-		if err := c.callbackFunc(ctx, msg); err != nil {
-			// 失败计数器累加
-			c.failureCounts[*msg]++
+		// 已处理成功但 ack 失败的消息不再重复处理, 只补充 ack
+		if _, ok := c.processed[msg.MsgID]; ok {
+			successMsgs = append(successMsgs, msg)
 			continue
 		}
 
-		// callback 执行成功，进行 ack
-		if err := c.client.XAck(ctx, c.topic, c.groupID, msg.MsgID); err != nil {
-			log.ErrorContextFormat(ctx, "msg ack failed, msg id: %s, err: %v", msg.MsgID, err)
+		// 手动确认模式下已交给使用方、尚未 ack 的消息仍在 pending 列表中, 不重复投递
+		if c.opts.manualAck && c.isInFlight(msg.MsgID) {
 			continue
 		}
 
-		delete(c.failureCounts, *msg)
+		// 处于退避期的失败消息暂不重试
+		if !c.retryable(msg) {
+			continue
+		}
+
+		c.opts.metrics.IncConsumed(c.topic, c.groupID)
+		msgCtx := ctx
+		if c.opts.manualAck {
+			msgCtx = withAcker(ctx, &msgAcker{consumer: c, msg: msg})
+		}
+		attempt := c.attempt(msg)
+		// 失败重试, 或者此前投递后未被 ack(例如进程崩溃)的消息均视为再次投递
+		msg.Redelivered = msg.Redelivered || attempt > 1 || msg.DeliveryCount > 1
+		// 在执行回调前记录在途消息, 回调中或从 Messages 取出后立即 ack 时才能正确移除
+		tracked := c.opts.manualAck && !c.ackOnReceive()
+		if tracked {
+			c.trackInFlight(msg.MsgID)
+		}
+		err := c.invokeCallback(msgCtx, msg)
+		c.opts.onResult(msgCtx, msg, err, attempt)
+		if err != nil {
+			if tracked {
+				c.releaseInFlight(msg.MsgID)
+			}
+			// 失败计数器累加, 读取时已确认的消息不会被再次投递, 无需记录
+			if !c.ackOnReceive() {
+				c.recordFailure(msg, c.opts.retryDecider(msg, err, attempt))
+			}
+			c.opts.metrics.IncFailed(c.topic, c.groupID)
+			continue
+		}
+		successMsgs = append(successMsgs, msg)
+	}
+
+	// NOACK 模式与 AckBeforeProcess 策略下读取时已经确认
+	if len(successMsgs) == 0 || c.ackOnReceive() {
+		return
+	}
+
+	// 手动确认模式下由使用方自行 ack
+	if c.opts.manualAck {
+		for _, msg := range successMsgs {
+			c.ResetFailures(msg.MsgID)
+		}
+		return
+	}
+
+	// callback 执行成功的消息，批量进行 ack
+	msgIDs := make([]string, 0, len(successMsgs))
+	for _, msg := range successMsgs {
+		msgIDs = append(msgIDs, msg.MsgID)
+		c.processed[msg.MsgID] = struct{}{}
+		c.ResetFailures(msg.MsgID)
+	}
+	acked, err := c.client.XAckBatch(ctx, c.topic, c.groupID, msgIDs...)
+	if err != nil {
+		c.opts.logger.ErrorFormat("msg ack failed, msg ids: %v, err: %v", msgIDs, err)
+		return
+	}
+	c.countAcked(msgIDs, acked)
+
+	for _, msgID := range msgIDs {
+		delete(c.processed, msgID)
 	}
 }
 
-func (c *Consumer) deliverDeadLetter(ctx context.Context) {
-	// 对于失败达到指定次数的消息，投递到死信中，然后执行 ack
-	for msg, failureCnt := range c.failureCounts {
-		if failureCnt < c.opts.maxRetryLimit {
-			continue
+// flushAcks consumer 停止时, 使用新的 context 对已处理成功但尚未 ack 的消息补充 ack, 减少重启后的重复处理
+func (c *Consumer) flushAcks() {
+	if len(c.processed) == 0 {
+		return
+	}
+
+	msgIDs := make([]string, 0, len(c.processed))
+	for msgID := range c.processed {
+		msgIDs = append(msgIDs, msgID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ackFlushTimeout)
+	defer cancel()
+
+	acked, err := c.client.XAckBatch(ctx, c.topic, c.groupID, msgIDs...)
+	if err != nil {
+		c.opts.logger.ErrorFormat("flush msg ack failed, msg ids: %v, err: %v", msgIDs, err)
+		return
+	}
+	c.countAcked(msgIDs, acked)
+
+	for _, msgID := range msgIDs {
+		delete(c.processed, msgID)
+	}
+}
+
+// invokeCallback 执行回调函数, 配置了 tracer 时会基于消息中携带的 trace 上下文创建子 span
+func (c *Consumer) invokeCallback(ctx context.Context, msg *redis.MsgEntity) error {
+	ctx, span := c.startSpan(c.restoreContextValues(ctx, msg), msg)
+	defer span.End()
+
+	if err := c.decompress(msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	start := c.opts.clock.Now()
+	err := c.safeCallback(ctx, msg)
+	c.opts.metrics.ObserveHandleLatency(c.topic, c.groupID, c.opts.clock.Now().Sub(start))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// safeCallback 执行回调函数, 回调函数 panic 时记录堆栈并转换为包装了 ErrCallbackPanic 的 error, 按处理失败计数
+func (c *Consumer) safeCallback(ctx context.Context, msg *redis.MsgEntity) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.opts.logger.ErrorFormat("msg callback panic, msg id: %s, panic: %v, stack: %s", msg.MsgID, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", ErrCallbackPanic, r)
 		}
+	}()
+
+	return c.callbackFunc(ctx, msg)
+}
+
+// attempt 返回消息当前是第几次被处理
+func (c *Consumer) attempt(msg *redis.MsgEntity) int {
+	if record, ok := c.failures.get(msg.MsgID); ok {
+		return record.count + 1
+	}
+	return 1
+}
 
-		// 投递死信队列
-		if err := c.opts.deadLetterMailbox.Deliver(ctx, &msg); err != nil {
-			log.ErrorContextFormat(c.ctx, "dead letter deliver failed, msg id: %s, err: %v", msg.MsgID, err)
+// recordFailure 累加消息的失败次数, 并计算下一次允许重试的时间. decision 不为 DecisionRetry 时直接达到重试上限
+func (c *Consumer) recordFailure(msg *redis.MsgEntity, decision Decision) {
+	c.failures.inc(msg, func(record *failureRecord) {
+		if decision != DecisionRetry && record.count < c.opts.maxRetryLimit {
+			record.count = c.opts.maxRetryLimit
+		}
+		// 按次数重试的消息达到上限后, 开启 WithDropAfterMaxRetry 时同样直接丢弃
+		record.drop = decision == DecisionDrop || (decision == DecisionRetry && c.opts.dropAfterMaxRetry)
+		record.nextRetryAt = c.opts.clock.Now().Add(jitter(c.retryBackoff(record.count), c.opts.backoffJitter))
+	})
+}
+
+// retryable 判断消息当前是否允许处理, 失败消息需要等待退避时间结束, 达到重试上限的消息等待投递死信队列, 不再处理
+func (c *Consumer) retryable(msg *redis.MsgEntity) bool {
+	record, ok := c.failures.get(msg.MsgID)
+	if !ok {
+		return true
+	}
+	return record.count < c.opts.maxRetryLimit && !c.opts.clock.Now().Before(record.nextRetryAt)
+}
+
+// retryBackoff 计算第 failureCnt 次失败后的退避时长, 按指数增长且不超过上限
+func (c *Consumer) retryBackoff(failureCnt int) time.Duration {
+	backoff := c.opts.retryBackoffBase
+	if backoff <= 0 {
+		return 0
+	}
+
+	for i := 1; i < failureCnt; i++ {
+		backoff *= 2
+		if backoff >= c.opts.retryBackoffMax {
+			return c.opts.retryBackoffMax
+		}
+	}
+
+	return backoff
+}
+
+func (c *Consumer) deliverDeadLetter(ctx context.Context) {
+	defer func() {
+		c.opts.metrics.SetFailureCount(c.topic, c.groupID, c.failures.len())
+	}()
+
+	// 对于失败达到指定次数的消息，投递到死信中，然后执行 ack
+	for _, record := range c.failures.exhausted(c.opts.maxRetryLimit) {
+		msg := record.msg
+		msgID := msg.MsgID
+
+		// 投递死信队列, 需要丢弃的消息直接 ack
+		if record.drop {
+			c.opts.logger.DebugFormat("drop msg, msg id: %s, failure count: %d", msg.MsgID, record.count)
+		} else {
+			if err := c.opts.deadLetterMailbox.Deliver(ctx, msg); err != nil {
+				c.opts.logger.ErrorFormat("dead letter deliver failed, msg id: %s, err: %v", msg.MsgID, err)
+				// 投递失败时不 ack, 保留失败记录, 下一轮重新投递
+				if !c.opts.ackOnDeadLetterFailure {
+					continue
+				}
+			} else {
+				c.opts.metrics.IncDeadLettered(c.topic, c.groupID)
+			}
 		}
 
 		// 执行 ack 响应
-		if err := c.client.XAck(ctx, c.topic, c.groupID, msg.MsgID); err != nil {
-			log.ErrorContextFormat(c.ctx, "msg ack failed, msg id: %s, err: %v", msg.MsgID, err)
+		acked, err := c.client.XAckWithResult(ctx, c.topic, c.groupID, msg.MsgID)
+		if err != nil {
+			c.opts.logger.ErrorFormat("msg ack failed, msg id: %s, err: %v", msg.MsgID, err)
 			continue
 		}
+		c.countAcked([]string{msgID}, boolToInt64(acked))
+		c.releaseInFlight(msgID)
 
 		// 对于 ack 成功的消息，将其从 failure map 中删除
-		delete(c.failureCounts, msg)
+		c.ResetFailures(msgID)
 	}
 }
+
+// ResetFailures 清除消息的失败次数与退避状态, 下次读取到该消息时按首次处理, 可以在修复下游故障后手动调用.
+// 可以在任意协程中调用
+func (c *Consumer) ResetFailures(msgID string) {
+	c.failures.delete(msgID)
+}
+
+// ResetAllFailures 清除所有消息的失败次数与退避状态, 可以在任意协程中调用
+func (c *Consumer) ResetAllFailures() {
+	c.failures.reset()
+}
+
+// PendingFailureCount 返回当前处于失败重试或等待投递死信队列状态的消息数, 持续增长通常意味着下游出现故障.
+// 可以在任意协程中调用
+func (c *Consumer) PendingFailureCount() int {
+	return c.failures.len()
+}
@@ -0,0 +1,73 @@
+package redis_mq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ConsumerGroup 在同一个 topic 与消费者组上运行多个消费者, 统一启动与停止.
+// 成员因运行期错误自行停止时不会影响其他成员, 可以通过 WithOnStopped 获得通知, 或者通过 Healthy 探测
+type ConsumerGroup struct {
+	consumers []*Consumer
+	startOnce sync.Once
+}
+
+// NewConsumerGroup 新建 n 个消费者, consumerID 依次为 consumerIDPrefix-0 ... consumerIDPrefix-(n-1),
+// consumerIDPrefix 为空时通过 GenerateConsumerID 自动生成. 所有消费者共用同一个回调函数与配置. 任意一个消费者初始化失败时, 已创建的消费者会被停止并返回错误.
+// 创建后需要调用 Start 开始消费
+func NewConsumerGroup(client ConsumerClient, topic, groupID, consumerIDPrefix string, n int, callbackFunc MsgCallback, opts ...ConsumerOption) (*ConsumerGroup, error) {
+	if n <= 0 {
+		return nil, errors.New("consumer group size must be positive")
+	}
+	if consumerIDPrefix == "" {
+		consumerIDPrefix = GenerateConsumerID(groupID)
+	}
+
+	g := &ConsumerGroup{consumers: make([]*Consumer, 0, n)}
+	for i := 0; i < n; i++ {
+		consumerID := fmt.Sprintf("%s-%d", consumerIDPrefix, i)
+		c := newConsumer(client, topic, groupID, consumerID, callbackFunc)
+		if err := c.init(opts...); err != nil {
+			g.Stop()
+			return nil, fmt.Errorf("init consumer %s failed: %w", consumerID, err)
+		}
+		g.consumers = append(g.consumers, c)
+	}
+
+	return g, nil
+}
+
+// Start 启动所有消费者, 重复调用无效
+func (g *ConsumerGroup) Start() {
+	g.startOnce.Do(func() {
+		for _, c := range g.consumers {
+			c.start()
+		}
+	})
+}
+
+// Stop 停止所有消费者并等待消费循环退出, 可以重复调用
+func (g *ConsumerGroup) Stop() {
+	for _, c := range g.consumers {
+		c.cancel()
+	}
+	for _, c := range g.consumers {
+		c.wait()
+	}
+}
+
+// Healthy 所有消费者的消费循环是否都在运行
+func (g *ConsumerGroup) Healthy() bool {
+	for _, c := range g.consumers {
+		if !c.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Consumers 返回组内的所有消费者, 可用于单独暂停或查询状态
+func (g *ConsumerGroup) Consumers() []*Consumer {
+	return g.consumers
+}
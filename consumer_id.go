@@ -0,0 +1,28 @@
+package redis_mq
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GenerateConsumerID 生成消费者 id, 格式为 prefix-hostname-pid-随机后缀, prefix 为空时省略.
+// 不同进程使用相同 consumerID 会共用同一个 pending 列表, 导致消息被重复处理或者互相认领
+func GenerateConsumerID(prefix string) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	parts := []string{hostname, fmt.Sprint(os.Getpid()), hex.EncodeToString(suffix)}
+	if prefix != "" {
+		parts = append([]string{prefix}, parts...)
+	}
+
+	return strings.Join(parts, "-")
+}
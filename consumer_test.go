@@ -0,0 +1,247 @@
+package redis_mq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/log"
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// stubConsumerClient 不依赖 redis 的 ConsumerClient, 读取消息时阻塞到 ctx 结束
+type stubConsumerClient struct{}
+
+var _ ConsumerClient = stubConsumerClient{}
+
+func (stubConsumerClient) XGroupCreateMkStream(context.Context, string, string, string) (string, error) {
+	return "OK", nil
+}
+
+func (stubConsumerClient) XReadGroupNewMsgWithArgs(ctx context.Context, _, _, _ string, _ redis.XReadGroupArgs) ([]*redis.MsgEntity, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stubConsumerClient) XReadGroupPendingMsg(ctx context.Context, _, _, _, _ string, _ redis.XReadGroupArgs) ([]*redis.MsgEntity, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stubConsumerClient) XPendingExt(context.Context, string, string, string, string, int, string) ([]*redis.PendingEntry, error) {
+	return nil, nil
+}
+
+func (stubConsumerClient) XPendingIdle(context.Context, string, string, time.Duration, int, string) ([]*redis.PendingEntry, error) {
+	return nil, nil
+}
+
+func (stubConsumerClient) XClaim(context.Context, string, string, string, time.Duration, ...string) ([]*redis.MsgEntity, error) {
+	return nil, nil
+}
+
+func (stubConsumerClient) XAck(context.Context, string, string, string) error {
+	return nil
+}
+
+func (stubConsumerClient) XAckWithResult(context.Context, string, string, string) (bool, error) {
+	return true, nil
+}
+
+func (stubConsumerClient) XAckBatch(_ context.Context, _, _ string, msgIDs ...string) (int64, error) {
+	return int64(len(msgIDs)), nil
+}
+
+func (stubConsumerClient) XRange(context.Context, string, string, string, int) ([]*redis.MsgEntity, error) {
+	return nil, nil
+}
+
+func (stubConsumerClient) Stats() redis.PoolStats {
+	return redis.PoolStats{}
+}
+
+func TestConsumerStopConcurrent(t *testing.T) {
+	c, err := NewConsumer(stubConsumerClient{}, "topic", "group", "consumer",
+		func(context.Context, *redis.MsgEntity) error { return nil },
+		WithConsumerLogger(log.NewNopLogger()))
+	if err != nil {
+		t.Fatalf("new consumer: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Stop()
+			c.Stop()
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("consumer loop still running after Stop returned")
+	}
+	if c.Healthy() {
+		t.Fatal("consumer is healthy after Stop")
+	}
+}
+
+// pendingStubClient 记录 XPENDING 与 XCLAIM 的调用, 其余操作同 stubConsumerClient
+type pendingStubClient struct {
+	stubConsumerClient
+
+	mu       sync.Mutex
+	entries  []*redis.PendingEntry
+	idleArgs []time.Duration
+	claimed  []string
+}
+
+func (s *pendingStubClient) XPendingIdle(_ context.Context, _, _ string, minIdle time.Duration, _ int, _ string) ([]*redis.PendingEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.idleArgs = append(s.idleArgs, minIdle)
+	return s.entries, nil
+}
+
+func (s *pendingStubClient) XClaim(_ context.Context, _, _, _ string, _ time.Duration, msgIDs ...string) ([]*redis.MsgEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]*redis.MsgEntity, 0, len(msgIDs))
+	for _, msgID := range msgIDs {
+		s.claimed = append(s.claimed, msgID)
+		msgs = append(msgs, &redis.MsgEntity{MsgID: msgID})
+	}
+	return msgs, nil
+}
+
+func (s *pendingStubClient) xPendingCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.idleArgs)
+}
+
+// newTestConsumer 新建未启动消费循环的 consumer
+func newTestConsumer(t *testing.T, client ConsumerClient, opts ...ConsumerOption) *Consumer {
+	t.Helper()
+
+	c := newConsumer(client, "topic", "group", "consumer", func(context.Context, *redis.MsgEntity) error { return nil })
+	opts = append([]ConsumerOption{WithConsumerLogger(log.NewNopLogger())}, opts...)
+	if err := c.init(opts...); err != nil {
+		t.Fatalf("init consumer: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func TestConsumerRetryBackoff(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestConsumer(t, stubConsumerClient{},
+		withClock(clock),
+		WithMaxRetryLimit(10),
+		WithRetryBackoff(time.Second, 4*time.Second))
+	msg := &redis.MsgEntity{MsgID: "1-0"}
+
+	if !c.retryable(msg) {
+		t.Fatal("new msg should be retryable")
+	}
+
+	// 退避时长按 1s, 2s, 4s 指数增长, 之后不超过上限 4s
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		c.recordFailure(msg, DecisionRetry)
+		if got := c.attempt(msg); got != i+2 {
+			t.Fatalf("failure %d: attempt = %d, want %d", i+1, got, i+2)
+		}
+
+		clock.Advance(backoff - time.Millisecond)
+		if c.retryable(msg) {
+			t.Fatalf("failure %d: retryable before backoff %s elapsed", i+1, backoff)
+		}
+		clock.Advance(time.Millisecond)
+		if !c.retryable(msg) {
+			t.Fatalf("failure %d: not retryable after backoff %s elapsed", i+1, backoff)
+		}
+	}
+}
+
+func TestConsumerRetryLimit(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestConsumer(t, stubConsumerClient{},
+		withClock(clock),
+		WithMaxRetryLimit(2),
+		WithRetryBackoff(time.Second, time.Second))
+	msg := &redis.MsgEntity{MsgID: "1-0"}
+
+	c.recordFailure(msg, DecisionRetry)
+	c.recordFailure(msg, DecisionRetry)
+	clock.Advance(time.Hour)
+	if c.retryable(msg) {
+		t.Fatal("msg reached retry limit should not be retryable")
+	}
+}
+
+func TestConsumerExpireStalePendingInterval(t *testing.T) {
+	clock := newFakeClock()
+	client := &pendingStubClient{entries: []*redis.PendingEntry{{MsgID: "1-0"}}}
+	c := newTestConsumer(t, client, withClock(clock), WithMaxPendingAge(5*time.Second))
+
+	c.expireStalePending()
+	if got := client.xPendingCalls(); got != 1 {
+		t.Fatalf("XPENDING calls = %d, want 1", got)
+	}
+	if client.idleArgs[0] != 5*time.Second {
+		t.Fatalf("XPENDING min idle = %s, want 5s", client.idleArgs[0])
+	}
+	if record, ok := c.failures.get("1-0"); !ok || record.count < c.opts.maxRetryLimit {
+		t.Fatal("stale pending msg should reach retry limit")
+	}
+
+	// 检查间隔不超过 maxPendingAge, 间隔内不重复查询
+	clock.Advance(5*time.Second - time.Millisecond)
+	c.expireStalePending()
+	if got := client.xPendingCalls(); got != 1 {
+		t.Fatalf("XPENDING calls within interval = %d, want 1", got)
+	}
+
+	clock.Advance(time.Millisecond)
+	c.expireStalePending()
+	if got := client.xPendingCalls(); got != 2 {
+		t.Fatalf("XPENDING calls after interval = %d, want 2", got)
+	}
+}
+
+func TestConsumerClaimIdlePending(t *testing.T) {
+	client := &pendingStubClient{entries: []*redis.PendingEntry{{MsgID: "1-0", DeliveryCount: 2, IdleTime: time.Minute}}}
+	c := newTestConsumer(t, client, WithPendingMinIdle(30*time.Second))
+
+	msgs, err := c.receivePending()
+	if err != nil {
+		t.Fatalf("receive pending: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].MsgID != "1-0" {
+		t.Fatalf("claimed msgs = %v, want [1-0]", msgs)
+	}
+	if client.idleArgs[0] != 30*time.Second {
+		t.Fatalf("XPENDING min idle = %s, want 30s", client.idleArgs[0])
+	}
+	if !msgs[0].Redelivered || msgs[0].DeliveryCount != 3 || msgs[0].IdleTime != time.Minute {
+		t.Fatalf("claimed msg = %+v, want redelivered with delivery count 3 and idle 1m", msgs[0])
+	}
+}
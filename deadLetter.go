@@ -2,6 +2,8 @@ package redis_mq
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/bing-bing-student/redis-mq/log"
 	"github.com/bing-bing-student/redis-mq/redis"
@@ -23,3 +25,50 @@ func (d *DeadLetterLogger) Deliver(ctx context.Context, msg *redis.MsgEntity) er
 	log.ErrorContextFormat(ctx, "msg fail execeed retry limit, msg id: %s", msg.MsgID)
 	return nil
 }
+
+// ReplayDeadLetter 将死信 stream dlTopic 中最早的 max 条消息重新写入 mainTopic, 写入成功后从 dlTopic 中删除, 用于修复问题后重新处理死信消息.
+// 消息的 key, val 与附加字段保持不变, mainTopic 按生产者的配置裁剪. 每条消息与 SendMsg 一样经过大小校验、限流、指标与链路追踪,
+// 但不做幂等校验. dlTopic 与 mainTopic 不能相同. max 不大于 0 时重放全部消息.
+// 遇到错误时停止并返回已重放的条数, 返回的 error 中包含失败消息的 id; 写入成功但删除失败的消息再次重放时会被重复写入
+func (p *Producer) ReplayDeadLetter(ctx context.Context, dlTopic, mainTopic string, max int) (int, error) {
+	if dlTopic == "" || mainTopic == "" {
+		return 0, fmt.Errorf("replay dead letter: %w", redis.ErrEmptyTopic)
+	}
+	// 重放写入的消息会被再次读取, 导致无限循环
+	if dlTopic == mainTopic {
+		return 0, errors.New("replay dead letter: dead letter topic and main topic can't be the same")
+	}
+	client, ok := p.client.(DeadLetterReplayClient)
+	if !ok {
+		return 0, errors.New("redis client doesn't support dead letter replay")
+	}
+
+	replayed := 0
+	for max <= 0 || replayed < max {
+		count := replayBatchSize
+		if max > 0 && max-replayed < count {
+			count = max - replayed
+		}
+
+		// 重放成功的消息会被删除, 因此每次都从头读取
+		msgs, err := client.XRange(ctx, dlTopic, "-", "+", count)
+		if err != nil {
+			return replayed, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		for _, msg := range msgs {
+			if _, err := p.send(ctx, mainTopic, msg.Key, msg.Val, sendOptions{replay: true, headers: msg.Headers}); err != nil {
+				return replayed, fmt.Errorf("replay dead letter msg %s failed: %w", msg.MsgID, err)
+			}
+			if _, err := client.XDel(ctx, dlTopic, msg.MsgID); err != nil {
+				return replayed, fmt.Errorf("delete replayed dead letter msg %s failed: %w", msg.MsgID, err)
+			}
+			replayed++
+		}
+	}
+
+	return replayed, nil
+}
@@ -0,0 +1,30 @@
+package redis_mq
+
+import (
+	"errors"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// Decision 消息处理失败后的处理方式
+type Decision int
+
+const (
+	// DecisionRetry 按照重试次数上限重试, 达到上限后投递到死信队列
+	DecisionRetry Decision = iota
+	// DecisionDeadLetter 不再重试, 直接投递到死信队列
+	DecisionDeadLetter
+	// DecisionDrop 不再重试, 也不投递到死信队列, 直接 ack 丢弃
+	DecisionDrop
+)
+
+// RetryDecider 根据回调函数返回的 error 决定失败消息的处理方式, attempt 为该消息当前是第几次被处理
+type RetryDecider func(msg *redis.MsgEntity, err error, attempt int) Decision
+
+// defaultRetryDecider 包装了 ErrPoisonMsg 的 error 直接投递到死信队列, 其余 error 按次数重试
+func defaultRetryDecider(_ *redis.MsgEntity, err error, _ int) Decision {
+	if errors.Is(err, ErrPoisonMsg) {
+		return DecisionDeadLetter
+	}
+	return DecisionRetry
+}
@@ -0,0 +1,101 @@
+package redis_mq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+func TestDefaultRetryDecider(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Decision
+	}{
+		{name: "plain error", err: errors.New("boom"), want: DecisionRetry},
+		{name: "poison msg", err: ErrPoisonMsg, want: DecisionDeadLetter},
+		{name: "wrapped poison msg", err: fmt.Errorf("decode: %w", ErrPoisonMsg), want: DecisionDeadLetter},
+		{name: "callback panic", err: fmt.Errorf("%w: oops", ErrCallbackPanic), want: DecisionRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultRetryDecider(&redis.MsgEntity{MsgID: "1-0"}, tt.err, 1); got != tt.want {
+				t.Fatalf("decision = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumerRetryBackoffDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		base, max  time.Duration
+		failureCnt int
+		want       time.Duration
+	}{
+		{name: "disabled", failureCnt: 3, want: 0},
+		{name: "first failure", base: 100 * time.Millisecond, max: time.Second, failureCnt: 1, want: 100 * time.Millisecond},
+		{name: "doubles", base: 100 * time.Millisecond, max: time.Second, failureCnt: 3, want: 400 * time.Millisecond},
+		{name: "capped", base: 100 * time.Millisecond, max: time.Second, failureCnt: 10, want: time.Second},
+		{name: "max below base", base: time.Second, max: time.Millisecond, failureCnt: 2, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConsumer(t, stubConsumerClient{}, WithRetryBackoff(tt.base, tt.max))
+			if got := c.retryBackoff(tt.failureCnt); got != tt.want {
+				t.Fatalf("retryBackoff(%d) = %s, want %s", tt.failureCnt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumerRecordFailureDecision(t *testing.T) {
+	tests := []struct {
+		name              string
+		decision          Decision
+		dropAfterMaxRetry bool
+		wantCount         int
+		wantDrop          bool
+	}{
+		{name: "retry", decision: DecisionRetry, wantCount: 1},
+		{name: "retry drop after max", decision: DecisionRetry, dropAfterMaxRetry: true, wantCount: 1, wantDrop: true},
+		{name: "dead letter", decision: DecisionDeadLetter, wantCount: 3},
+		{name: "drop", decision: DecisionDrop, wantCount: 3, wantDrop: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ConsumerOption{WithMaxRetryLimit(3)}
+			if tt.dropAfterMaxRetry {
+				opts = append(opts, WithDropAfterMaxRetry())
+			}
+			c := newTestConsumer(t, stubConsumerClient{}, opts...)
+
+			c.recordFailure(&redis.MsgEntity{MsgID: "1-0"}, tt.decision)
+			record, ok := c.failures.get("1-0")
+			if !ok {
+				t.Fatal("failure not recorded")
+			}
+			if record.count != tt.wantCount || record.drop != tt.wantDrop {
+				t.Fatalf("record count = %d, drop = %v, want %d, %v", record.count, record.drop, tt.wantCount, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := jitter(time.Second, 0); got != time.Second {
+		t.Fatalf("jitter without ratio = %s, want 1s", got)
+	}
+	for i := 0; i < 100; i++ {
+		got := jitter(time.Second, 0.2)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("jitter(1s, 0.2) = %s, out of range", got)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package redis_mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// moveDueMsgScript 将到期的延迟消息从有序集合原子地转移到 stream 中, 消息内容按 delayedItems 的格式存储在列表中.
+// 只有从有序集合中移除成功的消息才会被投递, 多个轮询器同时运行时不会重复投递
+// KEYS[1]: 延迟消息有序集合, KEYS[2]: stream, KEYS[3...]: 各条消息的内容列表
+// ARGV[1], ARGV[2]: 固定的 key/val 字段名, 为空时以 key 作为字段名, ARGV[3...]: 与 KEYS[3...] 对应的消息 id
+var moveDueMsgScript = redis.NewScript(`
+local moved = 0
+for i = 3, #KEYS do
+	if redis.call('ZREM', KEYS[1], ARGV[i]) == 1 then
+		local items = redis.call('LRANGE', KEYS[i], 0, -1)
+		if #items >= 6 then
+			local args = {KEYS[2]}
+			if items[1] ~= '' then
+				table.insert(args, items[1])
+			end
+			table.insert(args, items[2])
+			table.insert(args, items[3])
+			table.insert(args, items[4])
+			table.insert(args, '*')
+			if ARGV[2] ~= '' then
+				table.insert(args, ARGV[1])
+				table.insert(args, items[5])
+				table.insert(args, ARGV[2])
+				table.insert(args, items[6])
+			else
+				table.insert(args, items[5])
+				table.insert(args, items[6])
+			end
+			for j = 7, #items do
+				table.insert(args, items[j])
+			end
+			redis.call('XADD', unpack(args))
+			moved = moved + 1
+		end
+		redis.call('DEL', KEYS[i])
+	end
+end
+return moved
+`)
+
+// DelayedKey 返回 topic 对应的延迟消息有序集合 key, 使用 hash tag 保证与 stream 位于同一个 slot
+func DelayedKey(topic string) string {
+	return "{" + topic + "}:delayed"
+}
+
+// SendMsgAt 发送延迟消息, 消息先写入有序集合, 到达 deliverAt 后由 DelayedPoller 投递到 stream 中
+func (p *Producer) SendMsgAt(ctx context.Context, topic, key, val string, deliverAt time.Time) error {
+	if err := p.beginSend(); err != nil {
+		return err
+	}
+	defer p.sending.Done()
+
+	if topic == "" {
+		return fmt.Errorf("redis delayed msg: %w", redis.ErrEmptyTopic)
+	}
+	if err := p.checkMsgSize(key, val); err != nil {
+		return err
+	}
+
+	val, xAddArgs, err := p.xAddArgs(ctx, val, sendOptions{})
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(nonce)
+
+	return p.client.ZAddWithList(ctx, DelayedKey(topic), deliverAt.UnixMilli(), id,
+		delayedListKey(topic, id), delayedItems(xAddArgs, key, val))
+}
+
+// delayedListKey 返回延迟消息内容列表的 key, 与有序集合位于同一个 slot
+func delayedListKey(topic, id string) string {
+	return DelayedKey(topic) + ":" + id
+}
+
+// delayedItems 将 XADD 参数与消息内容编码为列表元素, 依次为 NOMKSTREAM(可为空)、裁剪方式、"~" 或 "="、
+// 裁剪阈值、key、val 以及 header 字段与值. 列表元素按原样存储, 二进制内容不会被破坏.
+// MINID 在发送时计算, 投递时沿用该值
+func delayedItems(xAddArgs redis.XAddArgs, key, val string) []string {
+	items := make([]string, 0, 6+2*len(xAddArgs.Headers))
+
+	noMkStream := ""
+	if xAddArgs.NoMkStream {
+		noMkStream = "NOMKSTREAM"
+	}
+	items = append(items, noMkStream)
+
+	trim := *xAddArgs.Trim
+	approx := "="
+	if trim.Approx {
+		approx = "~"
+	}
+	if trim.MinID != "" {
+		items = append(items, "MINID", approx, trim.MinID)
+	} else {
+		items = append(items, "MAXLEN", approx, strconv.FormatInt(trim.MaxLen, 10))
+	}
+
+	items = append(items, key, val)
+	for field, value := range xAddArgs.Headers {
+		items = append(items, field, value)
+	}
+	return items
+}
+
+// DelayedPoller 延迟消息轮询器, 定期将到期的延迟消息投递到 stream 中, 多个实例同时运行也不会重复投递
+type DelayedPoller struct {
+	ctx      context.Context
+	stop     context.CancelFunc
+	stopOnce sync.Once
+	// 轮询循环退出后关闭
+	done chan struct{}
+
+	client DelayedPollerClient
+	topic  string
+
+	opts *DelayedPollerOptions
+}
+
+// NewDelayedPoller 新建并启动延迟消息轮询器
+func NewDelayedPoller(client DelayedPollerClient, topic string, opts ...DelayedPollerOption) (*DelayedPoller, error) {
+	if client == nil {
+		return nil, errors.New("redis client can't be empty")
+	}
+	if topic == "" {
+		return nil, redis.ErrEmptyTopic
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	d := DelayedPoller{
+		ctx:    ctx,
+		stop:   stop,
+		done:   make(chan struct{}),
+		client: client,
+		topic:  topic,
+		opts:   &DelayedPollerOptions{},
+	}
+
+	for _, opt := range opts {
+		opt(d.opts)
+	}
+
+	repairDelayedPoller(d.opts)
+
+	go d.run()
+	return &d, nil
+}
+
+// Stop 停止轮询器并等待正在进行的转移完成, 可以重复或并发调用
+func (d *DelayedPoller) Stop() {
+	d.stopOnce.Do(d.stop)
+	<-d.done
+}
+
+func (d *DelayedPoller) run() {
+	defer close(d.done)
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.opts.clock.After(d.opts.pollInterval):
+		}
+
+		// 单轮转移满额时说明仍有到期消息, 继续转移
+		for d.ctx.Err() == nil {
+			due, err := d.moveDueMsg()
+			if err != nil {
+				if d.ctx.Err() == nil {
+					d.opts.logger.ErrorFormat("move delayed msg failed, topic: %s, err: %v", d.topic, err)
+				}
+				break
+			}
+			if due < d.opts.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// moveDueMsg 转移一批到期的延迟消息, 返回本轮查询到的到期消息条数
+func (d *DelayedPoller) moveDueMsg() (int, error) {
+	now := strconv.FormatInt(d.opts.clock.Now().UnixMilli(), 10)
+	ids, err := d.client.ZRangeByScore(d.ctx, DelayedKey(d.topic), "-inf", now, d.opts.batchSize)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	names := d.client.FieldNames()
+	keys := make([]string, 0, len(ids)+2)
+	keys = append(keys, DelayedKey(d.topic), d.topic)
+	args := make([]interface{}, 0, len(ids)+2)
+	args = append(args, names.Key, names.Val)
+	for _, id := range ids {
+		keys = append(keys, delayedListKey(d.topic, id))
+		args = append(args, id)
+	}
+
+	if _, err := d.client.EvalScript(d.ctx, moveDueMsgScript, keys, args); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
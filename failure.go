@@ -0,0 +1,92 @@
+package redis_mq
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// failureRecord 处理失败的消息及其累计失败次数
+type failureRecord struct {
+	msg   *redis.MsgEntity
+	count int
+	// 下一次允许重试的时间
+	nextRetryAt time.Time
+	// 达到重试上限后是否直接丢弃, 不投递死信队列
+	drop bool
+}
+
+// failureStore 并发安全的失败记录表, key 为消息 id. 读取时返回记录的副本, 修改只能通过 inc 进行
+type failureStore struct {
+	mu      sync.Mutex
+	records map[string]*failureRecord
+}
+
+func newFailureStore() *failureStore {
+	return &failureStore{records: make(map[string]*failureRecord)}
+}
+
+// get 返回消息的失败记录
+func (s *failureStore) get(msgID string) (failureRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[msgID]
+	if !ok {
+		return failureRecord{}, false
+	}
+	return *record, true
+}
+
+// inc 失败次数加一, 并在持有锁的情况下通过 update 更新记录的其他字段
+func (s *failureStore) inc(msg *redis.MsgEntity, update func(record *failureRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[msg.MsgID]
+	if !ok {
+		record = &failureRecord{msg: msg}
+		s.records[msg.MsgID] = record
+	}
+	record.count++
+	update(record)
+}
+
+// delete 删除消息的失败记录
+func (s *failureStore) delete(msgID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, msgID)
+}
+
+// reset 清空所有失败记录
+func (s *failureStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = make(map[string]*failureRecord)
+}
+
+// len 返回失败记录数
+func (s *failureStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.records)
+}
+
+// exhausted 返回失败次数达到 limit 的记录
+func (s *failureStore) exhausted(limit int) []failureRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []failureRecord
+	for _, record := range s.records {
+		if record.count >= limit {
+			records = append(records, *record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,90 @@
+package redis_mq
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+func TestFailureStore(t *testing.T) {
+	s := newFailureStore()
+	if _, ok := s.get("1-0"); ok {
+		t.Fatal("empty store returned a record")
+	}
+
+	retryAt := time.Unix(100, 0)
+	for i := 0; i < 3; i++ {
+		s.inc(&redis.MsgEntity{MsgID: "1-0"}, func(record *failureRecord) {
+			record.nextRetryAt = retryAt
+		})
+	}
+	s.inc(&redis.MsgEntity{MsgID: "2-0"}, func(*failureRecord) {})
+
+	record, ok := s.get("1-0")
+	if !ok || record.count != 3 || !record.nextRetryAt.Equal(retryAt) || record.msg.MsgID != "1-0" {
+		t.Fatalf("record = %+v, %v", record, ok)
+	}
+	if s.len() != 2 {
+		t.Fatalf("len = %d, want 2", s.len())
+	}
+
+	// get 返回副本, 修改不影响存储的记录
+	record.count = 100
+	if got, _ := s.get("1-0"); got.count != 3 {
+		t.Fatalf("stored count = %d after modifying copy, want 3", got.count)
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{limit: 1, want: []string{"1-0", "2-0"}},
+		{limit: 3, want: []string{"1-0"}},
+		{limit: 4, want: nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, record := range s.exhausted(tt.limit) {
+			got = append(got, record.msg.MsgID)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("exhausted(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+
+	s.delete("1-0")
+	if _, ok := s.get("1-0"); ok || s.len() != 1 {
+		t.Fatalf("record not deleted, len = %d", s.len())
+	}
+
+	s.reset()
+	if s.len() != 0 {
+		t.Fatalf("len after reset = %d, want 0", s.len())
+	}
+}
+
+func TestFailureStoreConcurrent(t *testing.T) {
+	s := newFailureStore()
+	msg := &redis.MsgEntity{MsgID: "1-0"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.inc(msg, func(*failureRecord) {})
+			s.get(msg.MsgID)
+			s.exhausted(1)
+		}()
+	}
+	wg.Wait()
+
+	if record, _ := s.get(msg.MsgID); record.count != 50 {
+		t.Fatalf("count = %d, want 50", record.count)
+	}
+}
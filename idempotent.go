@@ -0,0 +1,85 @@
+package redis_mq
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// ErrIdempotencyKeyPending 相同幂等 key 的消息正在发送中, 尚未得到消息 ID
+var ErrIdempotencyKeyPending = errors.New("message with the same idempotency key is being sent")
+
+// idempotencyAttemptLease 发送中占位的幂等 key 为每次发送尝试预留的时长, 写入消息 ID 后才延长到 idempotencyTTL.
+// 发送方在写入消息 ID 前崩溃时, 占位会在短时间内过期, 不会长时间阻塞重试
+const idempotencyAttemptLease = 10 * time.Second
+
+// idempotencyCleanupTimeout 发送失败后释放幂等 key 的超时时长, 不使用调用方可能已经结束的 ctx
+const idempotencyCleanupTimeout = time.Second
+
+// sendIdempotent 幂等发送消息. 先通过 SETNX 短期占用幂等 key, 占用成功后写入 stream 并将消息 ID 记录到幂等 key 中, 同时延长到 idempotencyTTL;
+// 幂等 key 已存在时直接返回记录的消息 ID
+func (p *Producer) sendIdempotent(ctx context.Context, topic, key, val string, so sendOptions) (*SendResult, error) {
+	idempotencyKey := p.opts.idempotencyKeyFunc(key, val)
+	if idempotencyKey == "" {
+		return p.xAdd(ctx, topic, key, val, so)
+	}
+
+	ttlSeconds := int64(p.opts.idempotencyTTL.Seconds())
+	_, err := p.client.SetNEX(ctx, idempotencyKey, "", p.pendingLeaseSeconds(ctx))
+	if err == nil {
+		return p.xAddAndStore(ctx, topic, key, val, idempotencyKey, ttlSeconds, so)
+	}
+	if !errors.Is(err, redis.ErrNil) {
+		return nil, err
+	}
+
+	// 幂等 key 已存在, 返回此前发送的消息 ID
+	msgID, err := p.client.Get(ctx, idempotencyKey)
+	if errors.Is(err, redis.ErrNil) {
+		// 幂等 key 恰好过期, 重新尝试发送
+		return p.sendIdempotent(ctx, topic, key, val, so)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msgID == "" {
+		return nil, ErrIdempotencyKeyPending
+	}
+
+	return &SendResult{ID: msgID}, nil
+}
+
+// pendingLeaseSeconds 返回占位的过期时间(秒), 需要覆盖 WithSendRetry 的全部尝试与退避, ctx 设置了更晚的截止时间时以截止时间为准.
+// 占位在限流等待之后才获取, 无需计入限流耗时
+func (p *Producer) pendingLeaseSeconds(ctx context.Context) int64 {
+	lease := time.Duration(p.opts.sendAttempts) * (idempotencyAttemptLease + p.opts.sendRetryBackoff)
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) + idempotencyAttemptLease; remaining > lease {
+			lease = remaining
+		}
+	}
+	return int64(math.Ceil(lease.Seconds()))
+}
+
+// xAddAndStore 写入 stream 并记录消息 ID, 写入失败时释放幂等 key 以便重试
+func (p *Producer) xAddAndStore(ctx context.Context, topic, key, val, idempotencyKey string, ttlSeconds int64, so sendOptions) (*SendResult, error) {
+	result, err := p.xAdd(ctx, topic, key, val, so)
+	if err != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), idempotencyCleanupTimeout)
+		defer cancel()
+		if delErr := p.client.Del(cleanupCtx, idempotencyKey); delErr != nil {
+			p.opts.logger.WarnFormat("release idempotency key failed, key: %s, err: %v", idempotencyKey, delErr)
+		}
+		return nil, err
+	}
+
+	// 消息已经发送成功, 记录消息 ID 失败时仅打印日志
+	if _, err := p.client.SetEX(ctx, idempotencyKey, result.ID, ttlSeconds); err != nil {
+		p.opts.logger.ErrorFormat("store idempotency msg id failed, key: %s, msg id: %s, err: %v", idempotencyKey, result.ID, err)
+	}
+
+	return result, nil
+}
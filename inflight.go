@@ -0,0 +1,77 @@
+package redis_mq
+
+import "time"
+
+// inFlightPollInterval 等待在途消息减少时的兜底轮询间隔
+const inFlightPollInterval = 100 * time.Millisecond
+
+// trackInFlight 记录已交给使用方但尚未 ack 的消息
+func (c *Consumer) trackInFlight(msgID string) {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+
+	c.inFlight[msgID] = struct{}{}
+}
+
+// releaseInFlight 消息 ack 后移除在途记录, 并唤醒等待中的消费循环
+func (c *Consumer) releaseInFlight(msgID string) {
+	c.inFlightMu.Lock()
+	_, ok := c.inFlight[msgID]
+	delete(c.inFlight, msgID)
+	c.inFlightMu.Unlock()
+
+	if !ok {
+		return
+	}
+	select {
+	case c.inFlightCh <- struct{}{}:
+	default:
+	}
+}
+
+// isInFlight 消息是否已交给使用方但尚未 ack
+func (c *Consumer) isInFlight(msgID string) bool {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+
+	_, ok := c.inFlight[msgID]
+	return ok
+}
+
+// inFlightCount 返回在途消息数
+func (c *Consumer) inFlightCount() int {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+
+	return len(c.inFlight)
+}
+
+// waitInFlight 在途消息数达到 WithMaxInFlight 上限时阻塞, 直到有消息被 ack. consumer 被停止时返回 false
+func (c *Consumer) waitInFlight() bool {
+	if c.opts.maxInFlight <= 0 {
+		return true
+	}
+
+	for c.inFlightCount() >= c.opts.maxInFlight {
+		select {
+		case <-c.ctx.Done():
+			return false
+		case <-c.inFlightCh:
+		case <-c.opts.clock.After(inFlightPollInterval):
+		}
+	}
+
+	return true
+}
+
+// readCount 返回本轮最多读取的新消息条数, 0 表示不限制
+func (c *Consumer) readCount() int {
+	if c.opts.maxInFlight <= 0 {
+		return 0
+	}
+
+	if n := c.opts.maxInFlight - c.inFlightCount(); n > 0 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,36 @@
+package redis_mq
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitterRand 计算抖动使用的随机数. 默认的全局随机数种子固定, 同时启动的进程会得到相同的序列, 因此使用随机种子
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(jitterSeed()))}
+
+func jitterSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// jitter 在 [d*(1-ratio), d*(1+ratio)] 范围内随机调整 d, ratio 为 0 时原样返回
+func jitter(d time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 || d <= 0 {
+		return d
+	}
+
+	jitterRand.Lock()
+	r := jitterRand.Float64()
+	jitterRand.Unlock()
+
+	return time.Duration(float64(d) * (1 + ratio*(2*r-1)))
+}
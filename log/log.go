@@ -2,6 +2,7 @@ package log
 
 import (
 	"context"
+	"sync"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -20,7 +21,9 @@ type Logger interface {
 }
 
 var (
-	defaultLogger Logger
+	// defaultLoggerMu 保护 defaultLogger, SetDefaultLogger 可能与正在打印日志的协程并发执行
+	defaultLoggerMu sync.RWMutex
+	defaultLogger   Logger
 )
 
 func init() {
@@ -133,11 +136,31 @@ func (w *zapLoggerWrapper) getLogWriter() zapcore.WriteSyncer {
 	})
 }
 
+// NewLogger 按照配置创建基于 zap 的日志实现, 可以通过 WithLogLevel 调整日志级别
+func NewLogger(opts ...Option) Logger {
+	return newSugarLogger(NewOptions(opts...))
+}
+
 // GetDefaultLogger 获取默认日志实现
 func GetDefaultLogger() Logger {
+	defaultLoggerMu.RLock()
+	defer defaultLoggerMu.RUnlock()
+
 	return defaultLogger
 }
 
+// SetDefaultLogger 替换默认日志实现, 传入 NewNopLogger() 可以关闭全部日志
+func SetDefaultLogger(logger Logger) {
+	if logger == nil {
+		return
+	}
+
+	defaultLoggerMu.Lock()
+	defer defaultLoggerMu.Unlock()
+
+	defaultLogger = logger
+}
+
 // DebugFormat 打印 Debug 日志
 func DebugFormat(format string, args ...interface{}) {
 	GetDefaultLogger().DebugFormat(format, args...)
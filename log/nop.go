@@ -0,0 +1,25 @@
+package log
+
+// nopLogger 不输出任何日志
+type nopLogger struct{}
+
+// NewNopLogger 创建不输出任何日志的实现, 适用于测试或者不需要日志的部署环境
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Error(...interface{}) {}
+
+func (nopLogger) Warn(...interface{}) {}
+
+func (nopLogger) Info(...interface{}) {}
+
+func (nopLogger) Debug(...interface{}) {}
+
+func (nopLogger) ErrorFormat(string, ...interface{}) {}
+
+func (nopLogger) WarnFormat(string, ...interface{}) {}
+
+func (nopLogger) InfoFormat(string, ...interface{}) {}
+
+func (nopLogger) DebugFormat(string, ...interface{}) {}
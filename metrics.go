@@ -0,0 +1,33 @@
+package redis_mq
+
+import "time"
+
+// MetricsCollector 指标采集器, 与具体的监控系统无关, 使用方可以自行实现以接入任意监控体系
+// 开箱即用的 prometheus 实现见 metrics 包
+type MetricsCollector interface {
+	// IncProduced 生产消息成功
+	IncProduced(topic string)
+	// IncConsumed 接收到消息
+	IncConsumed(topic, group string)
+	// IncAcked 消息确认成功
+	IncAcked(topic, group string, count int)
+	// IncFailed 回调函数处理消息失败
+	IncFailed(topic, group string)
+	// IncDeadLettered 消息被投递到死信队列
+	IncDeadLettered(topic, group string)
+	// ObserveHandleLatency 回调函数处理单条消息的耗时
+	ObserveHandleLatency(topic, group string, latency time.Duration)
+	// SetFailureCount 当前处理失败且等待重试的消息数量
+	SetFailureCount(topic, group string, count int)
+}
+
+// noopMetrics 未注入采集器时使用的空实现
+type noopMetrics struct{}
+
+func (noopMetrics) IncProduced(string)                                 {}
+func (noopMetrics) IncConsumed(string, string)                         {}
+func (noopMetrics) IncAcked(string, string, int)                       {}
+func (noopMetrics) IncFailed(string, string)                           {}
+func (noopMetrics) IncDeadLettered(string, string)                     {}
+func (noopMetrics) ObserveHandleLatency(string, string, time.Duration) {}
+func (noopMetrics) SetFailureCount(string, string, int)                {}
@@ -0,0 +1,244 @@
+// Package mqtest 提供内存版的 redis 客户端, 用于在没有 redis 的环境下测试生产者
+package mqtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	redis_mq "github.com/bing-bing-student/redis-mq"
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+var _ redis_mq.ProducerClient = (*FakeClient)(nil)
+
+// Msg FakeClient 记录的一条消息
+type Msg struct {
+	Topic   string
+	ID      string
+	Key     string
+	Val     string
+	Headers map[string]string
+	// Trim 写入时指定的裁剪策略, FakeClient 只记录不执行
+	Trim *redis.TrimStrategy
+}
+
+// FakeClient 内存版的 redis 客户端, 实现了 redis_mq.ProducerClient.
+// 写入的消息按顺序记录在内存中, 只记录裁剪策略, 不执行裁剪与过期, 并发安全
+type FakeClient struct {
+	mu      sync.Mutex
+	seq     int64
+	msgs    []Msg
+	kv      map[string]string
+	lists   map[string][]string
+	delayed map[string]map[string]int64
+}
+
+// NewFakeClient 新建内存版的 redis 客户端
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		kv:      make(map[string]string),
+		lists:   make(map[string][]string),
+		delayed: make(map[string]map[string]int64),
+	}
+}
+
+// Msgs 返回已写入的全部消息
+func (f *FakeClient) Msgs() []Msg {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Msg(nil), f.msgs...)
+}
+
+// TopicMsgs 返回写入指定 topic 的消息
+func (f *FakeClient) TopicMsgs(topic string) []Msg {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var msgs []Msg
+	for _, msg := range f.msgs {
+		if msg.Topic == topic {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Delayed 返回有序集合 key 中的成员及其分数
+func (f *FakeClient) Delayed(key string) map[string]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	members := make(map[string]int64, len(f.delayed[key]))
+	for member, score := range f.delayed[key] {
+		members[member] = score
+	}
+	return members
+}
+
+// List 返回列表 key 中的全部元素
+func (f *FakeClient) List(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.lists[key]...)
+}
+
+// Reset 清空记录的消息与数据
+func (f *FakeClient) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq = 0
+	f.msgs = nil
+	f.kv = make(map[string]string)
+	f.lists = make(map[string][]string)
+	f.delayed = make(map[string]map[string]int64)
+}
+
+func (f *FakeClient) XAddMsgWithArgs(_ context.Context, topic string, xAddArgs redis.XAddArgs, key, val string) (string, error) {
+	if topic == "" {
+		return "", fmt.Errorf("redis XADD: %w", redis.ErrEmptyTopic)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if xAddArgs.NoMkStream && !f.hasTopic(topic) {
+		return "", redis.ErrNoStream
+	}
+
+	f.seq++
+	msg := Msg{
+		Topic: topic,
+		ID:    fmt.Sprintf("%d-0", f.seq),
+		Key:   key,
+		Val:   val,
+	}
+	if xAddArgs.Trim != nil {
+		trim := *xAddArgs.Trim
+		msg.Trim = &trim
+	}
+	if len(xAddArgs.Headers) > 0 {
+		msg.Headers = make(map[string]string, len(xAddArgs.Headers))
+		for field, value := range xAddArgs.Headers {
+			msg.Headers[field] = value
+		}
+	}
+	f.msgs = append(f.msgs, msg)
+
+	return msg.ID, nil
+}
+
+// XAddMsgWithStats 与 XAddMsgWithArgs 相同, FakeClient 不裁剪消息, Trimmed 始终为 0
+func (f *FakeClient) XAddMsgWithStats(ctx context.Context, topic string, xAddArgs redis.XAddArgs, key, val string) (*redis.XAddResult, error) {
+	id, err := f.XAddMsgWithArgs(ctx, topic, xAddArgs, key, val)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.XAddResult{ID: id}, nil
+}
+
+// XAddBatch 依次写入每条消息, 单条消息失败不影响其他消息
+func (f *FakeClient) XAddBatch(ctx context.Context, entries []redis.XAddEntry) ([]redis.XAddEntryResult, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("redis XADD entries can't be empty")
+	}
+
+	results := make([]redis.XAddEntryResult, len(entries))
+	for i, entry := range entries {
+		results[i].ID, results[i].Err = f.XAddMsgWithArgs(ctx, entry.Topic, entry.Args, entry.Key, entry.Val)
+	}
+	return results, nil
+}
+
+func (f *FakeClient) Get(_ context.Context, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("redis GET: %w", redis.ErrEmptyKey)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	val, ok := f.kv[key]
+	if !ok {
+		return "", redis.ErrNil
+	}
+	return val, nil
+}
+
+// SetEX 设置 key 的值, FakeClient 不处理过期时间
+func (f *FakeClient) SetEX(_ context.Context, key, value string, _ int64) (int64, error) {
+	if key == "" {
+		return -1, fmt.Errorf("redis SET EX: %w", redis.ErrEmptyKey)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.kv[key] = value
+	return 1, nil
+}
+
+// SetNEX key 不存在时设置 key 的值, key 已存在时与 redis 一致返回 redis.ErrNil
+func (f *FakeClient) SetNEX(_ context.Context, key, value string, _ int64) (int64, error) {
+	if key == "" {
+		return -1, fmt.Errorf("redis SET EX NX: %w", redis.ErrEmptyKey)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.kv[key]; ok {
+		return 0, redis.ErrNil
+	}
+	f.kv[key] = value
+	return 1, nil
+}
+
+func (f *FakeClient) Del(_ context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("redis DEL: %w", redis.ErrEmptyKey)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.kv, key)
+	return nil
+}
+
+// ZAddWithList 将 items 写入列表 listKey 并向有序集合 key 添加成员
+func (f *FakeClient) ZAddWithList(_ context.Context, key string, score int64, member, listKey string, items []string) error {
+	if key == "" || listKey == "" {
+		return fmt.Errorf("redis ZADD: %w", redis.ErrEmptyKey)
+	}
+	if len(items) == 0 {
+		return errors.New("redis RPUSH items can't be empty")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lists[listKey] = append([]string(nil), items...)
+
+	members, ok := f.delayed[key]
+	if !ok {
+		members = make(map[string]int64)
+		f.delayed[key] = members
+	}
+	members[member] = score
+	return nil
+}
+
+func (f *FakeClient) hasTopic(topic string) bool {
+	for _, msg := range f.msgs {
+		if msg.Topic == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package redis_mq
+
+import (
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// keyspaceStreamClass stream 类型的 keyspace 通知
+const keyspaceStreamClass = "t"
+
+// startKeyspaceNotify 订阅 topic 的 keyspace 通知, 不满足条件时记录日志并保持阻塞读取的方式
+func (c *Consumer) startKeyspaceNotify() {
+	client, ok := c.client.(KeyspaceNotifyClient)
+	if !ok {
+		c.opts.logger.WarnFormat("client doesn't support keyspace notification, fallback to polling, topic: %s", c.topic)
+		return
+	}
+
+	enabled, err := client.KeyspaceEventsEnabled(c.ctx, keyspaceStreamClass)
+	if err != nil {
+		c.opts.logger.WarnFormat("query keyspace notification config failed, fallback to polling, topic: %s, err: %v", c.topic, err)
+		return
+	}
+	if !enabled {
+		c.opts.logger.WarnFormat("keyspace notification for stream isn't enabled, fallback to polling, topic: %s", c.topic)
+		return
+	}
+
+	sub, err := client.Subscribe(c.ctx, client.KeyspaceChannel(c.topic))
+	if err != nil {
+		c.opts.logger.WarnFormat("subscribe keyspace notification failed, fallback to polling, topic: %s, err: %v", c.topic, err)
+		return
+	}
+
+	c.wakeCh = make(chan struct{}, 1)
+	c.notifyActive.Store(true)
+	go c.forwardKeyspaceNotify(sub)
+}
+
+// forwardKeyspaceNotify 收到 xadd 通知时唤醒消费循环, 订阅异常结束后退回阻塞读取
+func (c *Consumer) forwardKeyspaceNotify(sub *redis.Subscription) {
+	for msg := range sub.Messages() {
+		if msg.Data != "xadd" {
+			continue
+		}
+		c.wake()
+	}
+
+	c.notifyActive.Store(false)
+	// 唤醒等待中的消费循环, 使其立即以阻塞方式读取
+	c.wake()
+	if c.ctx.Err() == nil {
+		c.opts.logger.WarnFormat("keyspace notification subscription closed, fallback to polling, topic: %s, err: %v", c.topic, sub.Err())
+	}
+}
+
+// wake 通知消费循环读取新消息, 已有未处理的通知时直接合并
+func (c *Consumer) wake() {
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// waitWake 等待 keyspace 通知, 最多等待 receiveTimeout, consumer 停止时立即返回
+func (c *Consumer) waitWake() {
+	select {
+	case <-c.ctx.Done():
+	case <-c.wakeCh:
+	case <-c.opts.clock.After(jitter(c.opts.receiveTimeout, c.opts.backoffJitter)):
+	}
+}
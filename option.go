@@ -1,9 +1,58 @@
 package redis_mq
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bing-bing-student/redis-mq/log"
+	"github.com/bing-bing-student/redis-mq/redis"
+)
 
 type ProducerOptions struct {
+	// 每个 topic 最多保留的消息条数
 	msgQueueLen int
+	// 是否使用近似裁剪
+	approxTrim bool
+	// 按消息 ID 裁剪时, 每次发送前计算 MINID 的函数
+	minIDFunc func() string
+	// topic 不存在时是否禁止自动创建
+	noMkStream bool
+	// 指标采集器
+	metrics MetricsCollector
+	// 链路追踪
+	tracer trace.Tracer
+	// 根据消息计算幂等 key 的函数
+	idempotencyKeyFunc func(key, val string) string
+	// 幂等 key 的保留时长
+	idempotencyTTL time.Duration
+	// 消息序列化方式
+	codec Codec
+	// 消息压缩算法, 为 nil 时不压缩
+	compressor Compressor
+	// 单条消息 key 与 val 的最大字节数, 为 0 时不限制
+	maxMsgBytes int
+	// 每秒最多发送的消息条数, 为 0 时不限制
+	rateLimit int
+	// 连接层面错误时 XADD 的最大尝试次数
+	sendAttempts int
+	// XADD 重试的间隔
+	sendRetryBackoff time.Duration
+	// 需要写入消息附加字段的 ctx 值
+	contextFields []ContextField
+	// 日志
+	logger log.Logger
+	// 生产者是否持有 redis 客户端, 持有时 Close 会关闭客户端
+	ownedClient bool
+	// 异步发送缓冲区的容量
+	asyncBufferSize int
+	// 异步发送单批最多写入的消息条数
+	asyncBatchSize int
+	// 异步发送的最长攒批时间
+	asyncFlushInterval time.Duration
+	// 异步发送的消息被丢弃时触发的钩子
+	onAsyncDropped AsyncDropHook
 }
 
 type ProducerOption func(opts *ProducerOptions)
@@ -14,36 +63,325 @@ func WithMsgQueueLen(len int) ProducerOption {
 	}
 }
 
+// WithApproxTrim 使用近似裁剪(MAXLEN ~), redis 只会整块删除宏节点, 高吞吐下开销远小于精确裁剪,
+// 代价是 stream 中实际保留的消息条数可能略多于 msgQueueLen. 默认使用精确裁剪
+func WithApproxTrim() ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.approxTrim = true
+	}
+}
+
+// WithMinIDRetention 按消息 ID 裁剪(MINID), 每次发送前通过 minIDFunc 计算截止 ID, 早于该 ID 的消息会被删除.
+// 设置后 msgQueueLen 不再生效. 例如仅保留最近 24 小时的消息:
+//
+//	WithMinIDRetention(func() string { return redis.MinIDFromTime(time.Now().Add(-24 * time.Hour)) })
+func WithMinIDRetention(minIDFunc func() string) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.minIDFunc = minIDFunc
+	}
+}
+
+// WithNoMkStream 发送消息时不自动创建 stream, topic 不存在时 SendMsg 返回 redis.ErrNoStream,
+// 避免 topic 拼写错误时悄悄创建出无人消费的 stream
+func WithNoMkStream() ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.noMkStream = true
+	}
+}
+
+// WithProducerMetrics 注入指标采集器
+func WithProducerMetrics(collector MetricsCollector) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.metrics = collector
+	}
+}
+
+// WithProducerTracer 注入链路追踪, 发送消息时会创建 span 并将 trace 上下文(traceparent)写入消息的附加字段
+func WithProducerTracer(tracer trace.Tracer) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.tracer = tracer
+	}
+}
+
+// WithIdempotencyKey 幂等发送, 发送前通过 keyFunc 计算幂等 key 并执行 SETNX,
+// 幂等 key 已存在时不会重复发送, 而是返回此前发送的消息 ID
+func WithIdempotencyKey(keyFunc func(key, val string) string) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.idempotencyKeyFunc = keyFunc
+	}
+}
+
+// WithIdempotencyTTL 幂等 key 的保留时长, 超过该时长后相同的消息可以再次发送, 默认 24 小时
+func WithIdempotencyTTL(ttl time.Duration) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.idempotencyTTL = ttl
+	}
+}
+
+// WithProducerCodec 设置 SendValue 与 TypedProducer 使用的序列化方式, 默认 JSON
+func WithProducerCodec(codec Codec) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithProducerCompression 发送前使用 compressor 压缩消息 val, 并在附加字段中标记压缩算法,
+// 消费方会根据标记自动解压, 未标记的消息按未压缩处理, 因此可以滚动升级
+func WithProducerCompression(compressor Compressor) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.compressor = compressor
+	}
+}
+
+// WithMaxMsgBytes 限制单条消息 key 与 val 的总字节数, 超过时 SendMsg 直接返回 ErrMsgTooLarge, 不会写入 redis
+func WithMaxMsgBytes(n int) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.maxMsgBytes = n
+	}
+}
+
+// WithRateLimit 限制每秒最多发送 perSecond 条消息(令牌桶), 超过时 SendMsg 会阻塞等待, 直到获得令牌或 ctx 结束
+func WithRateLimit(perSecond int) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.rateLimit = perSecond
+	}
+}
+
+// WithSendRetry XADD 遇到连接断开, 超时等临时错误时进行重试, attempts 为包含首次发送在内的最大尝试次数,
+// 每次重试前等待 backoff. redis 返回的业务错误不会重试
+func WithSendRetry(attempts int, backoff time.Duration) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.sendAttempts = attempts
+		opts.sendRetryBackoff = backoff
+	}
+}
+
+// WithProducerContextFields 发送消息时将 ctx 中 fields 对应的 string 值写入消息附加字段,
+// 消费方通过 WithConsumerContextFields 配置相同的 fields 后即可在回调函数的 ctx 中取到这些值
+func WithProducerContextFields(fields ...ContextField) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.contextFields = append(opts.contextFields, fields...)
+	}
+}
+
+// WithOwnedClient 声明 redis 客户端归生产者所有, Producer.Close 时会一并关闭客户端的连接池.
+// 客户端被多个生产者或消费者共用时不要设置, 默认 Close 不会关闭客户端
+func WithOwnedClient() ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.ownedClient = true
+	}
+}
+
+// WithAsyncBufferSize 设置 SendAsync 缓冲区最多容纳的消息条数, 缓冲区已满时新消息会被丢弃, 默认 10000
+func WithAsyncBufferSize(n int) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.asyncBufferSize = n
+	}
+}
+
+// WithAsyncBatch 设置 SendAsync 的攒批策略, 攒够 size 条或距离上次写入超过 interval 时通过 pipeline 批量写入, 默认 100 条, 100ms
+func WithAsyncBatch(size int, interval time.Duration) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.asyncBatchSize = size
+		opts.asyncFlushInterval = interval
+	}
+}
+
+// WithOnAsyncDropped SendAsync 的消息因缓冲区已满或写入失败被丢弃时触发 hook, 默认记录错误日志
+func WithOnAsyncDropped(hook AsyncDropHook) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.onAsyncDropped = hook
+	}
+}
+
+// WithProducerLogger 设置日志实现, 默认使用 log.GetDefaultLogger(), 传入 log.NewNopLogger() 可以关闭日志
+func WithProducerLogger(logger log.Logger) ProducerOption {
+	return func(opts *ProducerOptions) {
+		opts.logger = logger
+	}
+}
+
 func repairProducer(opts *ProducerOptions) {
 	if opts.msgQueueLen <= 0 {
 		opts.msgQueueLen = 500
 	}
+
+	if opts.metrics == nil {
+		opts.metrics = noopMetrics{}
+	}
+
+	if opts.tracer == nil {
+		opts.tracer = trace.NewNoopTracerProvider().Tracer("")
+	}
+
+	if opts.idempotencyTTL < time.Second {
+		opts.idempotencyTTL = 24 * time.Hour
+	}
+
+	if opts.codec == nil {
+		opts.codec = JSONCodec{}
+	}
+
+	if opts.maxMsgBytes < 0 {
+		opts.maxMsgBytes = 0
+	}
+
+	if opts.rateLimit < 0 {
+		opts.rateLimit = 0
+	}
+
+	if opts.sendAttempts <= 0 {
+		opts.sendAttempts = 1
+	}
+
+	if opts.sendRetryBackoff < 0 {
+		opts.sendRetryBackoff = 0
+	}
+
+	if opts.logger == nil {
+		opts.logger = log.GetDefaultLogger()
+	}
+
+	if opts.asyncBufferSize <= 0 {
+		opts.asyncBufferSize = 10000
+	}
+
+	if opts.asyncBatchSize <= 0 {
+		opts.asyncBatchSize = 100
+	}
+
+	if opts.asyncFlushInterval <= 0 {
+		opts.asyncFlushInterval = 100 * time.Millisecond
+	}
+
+	if opts.onAsyncDropped == nil {
+		logger := opts.logger
+		opts.onAsyncDropped = func(topic, key, _ string, err error) {
+			logger.ErrorFormat("async msg dropped, topic: %s, key: %s, err: %v", topic, key, err)
+		}
+	}
 }
 
 type ConsumerOptions struct {
 	// 每轮接收消息的超时时长
 	receiveTimeout time.Duration
+	// 是否无限阻塞等待新消息
+	infiniteBlock bool
+	// 自适应阻塞时长的下限与上限, 上限为 0 时不开启
+	adaptiveBlockMin time.Duration
+	adaptiveBlockMax time.Duration
 	// 处理消息的最大重试次数，超过此次数时，消息会被投递到死信队列
 	maxRetryLimit int
+	// 是否显式设置了 maxRetryLimit, 用于区分未设置与设置为 0
+	maxRetryLimitSet bool
 	// 死信队列，可以由使用方自定义实现
 	deadLetterMailbox DeadLetterMailbox
 	// 投递死信流程超时阈值
 	deadLetterDeliverTimeout time.Duration
+	// 投递死信队列失败时是否仍然 ack
+	ackOnDeadLetterFailure bool
 	// 处理消息流程超时阈值
 	handleMsgTimeout time.Duration
+	// 是否在启动时自动创建消费者组
+	autoCreateGroup bool
+	// 自动创建消费者组时的起始消费位置
+	groupStartID string
+	// 指标采集器
+	metrics MetricsCollector
+	// 链路追踪
+	tracer trace.Tracer
+	// 是否由使用方手动确认消息
+	manualAck bool
+	// 是否以 NOACK 方式读取消息
+	noAck bool
+	// 自动确认消息的时机
+	ackPolicy AckPolicy
+	// 是否通过 keyspace 通知唤醒读取
+	keyspaceNotify bool
+	// 最多允许的在途消息数
+	maxInFlight int
+	// 是否跳过格式错误的消息
+	skipInvalidMsg bool
+	// pending 消息被重新认领前的最小空闲时长
+	pendingMinIdle time.Duration
+	// pending 消息的最大空闲时长, 超过后直接投递死信队列
+	maxPendingAge time.Duration
+	// 每次执行回调函数后触发的钩子
+	onResult ResultHook
+	// 决定失败消息的处理方式
+	retryDecider RetryDecider
+	// 达到重试上限后是否直接丢弃, 不投递死信队列
+	dropAfterMaxRetry bool
+	// 失败重试的初始退避时长
+	retryBackoffBase time.Duration
+	// 失败重试的最大退避时长
+	retryBackoffMax time.Duration
+	// 等待时长的随机抖动比例
+	backoffJitter float64
+	// 消息序列化方式
+	codec Codec
+	// 可用于解压的压缩算法, key 为算法名称
+	compressors map[string]Compressor
+	// 每轮最多读取的 pending 消息条数
+	pendingBatchSize int
+	// 连接池耗尽时重新接收消息前的退避时长
+	poolExhaustedBackoff time.Duration
+	// 连接池耗尽时触发的钩子
+	onPoolExhausted PoolExhaustedHook
+	// consumer 因运行期错误自行停止时触发的钩子
+	onStopped StoppedHook
+	// 需要从消息附加字段还原到 ctx 中的值
+	contextFields []ContextField
+	// 日志
+	logger log.Logger
+	// 时间来源
+	clock clock
 }
 
 type ConsumerOption func(opts *ConsumerOptions)
 
+// WithReceiveTimeout 每轮阻塞等待新消息(XREADGROUP BLOCK)的时长, 默认 2s. 超时后会处理死信与 pending 消息再进入下一轮.
+// 设置为 0 或负数时使用默认值, 无限阻塞需要通过 WithInfiniteBlock 显式开启.
+// 阻塞期间调用 Stop 会立即中断本次读取. 客户端设置了 redis.WithReadTimeout 时, 读超时需要大于该时长
 func WithReceiveTimeout(timeout time.Duration) ConsumerOption {
 	return func(opts *ConsumerOptions) {
 		opts.receiveTimeout = timeout
 	}
 }
 
+// WithInfiniteBlock 阻塞等待新消息时不设置超时(BLOCK 0), 直到有新消息到来或者 Stop 才返回, 设置后 receiveTimeout 不再生效.
+// 由于每轮只有收到新消息后才会处理死信与 pending 消息, topic 长时间没有新消息时失败消息不会被及时重试.
+// 客户端不能设置 redis.WithReadTimeout, 否则读取会在读超时后失败
+func WithInfiniteBlock() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.infiniteBlock = true
+	}
+}
+
+// WithKeyspaceNotify 订阅 topic 的 keyspace 通知, 以非阻塞方式读取新消息, 没有新消息时等待 xadd 通知唤醒, 等待期间不占用连接池中的连接.
+// 仍会每隔 receiveTimeout 读取一次, 以免错过通知. 服务端未开启 stream 类型的 keyspace 通知(notify-keyspace-events 包含 K 与 t)、
+// 无法查询配置或者订阅中断时, 退回阻塞读取(BLOCK)的方式
+func WithKeyspaceNotify() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.keyspaceNotify = true
+	}
+}
+
+// WithAdaptiveBlock 自适应调整每轮阻塞等待新消息的时长: 读取到消息时缩短为 min, 没有消息时逐轮翻倍直到 max, 设置后 receiveTimeout 不再生效.
+// 消息密集时每轮很快返回, 及时处理 pending 与死信消息; 空闲时减少对 redis 的轮询. max 不大于 0 时不开启, min 不大于 0 时默认 100ms
+func WithAdaptiveBlock(min, max time.Duration) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.adaptiveBlockMin = min
+		opts.adaptiveBlockMax = max
+	}
+}
+
+// WithMaxRetryLimit 消息处理失败的次数达到 maxRetryLimit 后投递到死信队列, 未设置时默认为 3.
+// 显式设置为 0 与 1 效果相同, 即第一次失败后就投递到死信队列, 不再重试; 设置为负数时使用默认值
 func WithMaxRetryLimit(maxRetryLimit int) ConsumerOption {
 	return func(opts *ConsumerOptions) {
 		opts.maxRetryLimit = maxRetryLimit
+		opts.maxRetryLimitSet = true
 	}
 }
 
@@ -59,18 +397,236 @@ func WithDeadLetterDeliverTimeout(timeout time.Duration) ConsumerOption {
 	}
 }
 
+// WithMaxPendingAge 消费者组中空闲时长超过 d 的 pending 消息(以 XPENDING 返回的空闲时长为准), 无论投递了多少次都会被认领并投递到死信队列,
+// 避免消息因为反复被认领或者消费者下线而永久滞留在 pending 列表中. 最多每 10s 检查一次, 默认不开启
+func WithMaxPendingAge(d time.Duration) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.maxPendingAge = d
+	}
+}
+
+// WithAckOnDeadLetterFailure 投递死信队列失败时仍然 ack 消息, 即旧版本的行为, 死信队列不可用时消息会丢失.
+// 默认投递失败的消息不会 ack, 继续留在 pending 列表中, 并在之后每一轮重新尝试投递
+func WithAckOnDeadLetterFailure() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.ackOnDeadLetterFailure = true
+	}
+}
+
 func WithHandleMsgTimeout(timeout time.Duration) ConsumerOption {
 	return func(opts *ConsumerOptions) {
 		opts.handleMsgTimeout = timeout
 	}
 }
 
+// WithAutoCreateGroup 启动时自动创建消费者组(stream 不存在时一并创建), 消费者组已存在时忽略.
+// startID 为新消费者组开始消费的位置, 可以是 0-0(从头消费), $(只消费新消息) 或者具体的消息 ID,
+// 为空时使用 WithGroupStartID 设置的位置
+func WithAutoCreateGroup(startID string) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.autoCreateGroup = true
+		if startID != "" {
+			opts.groupStartID = startID
+		}
+	}
+}
+
+// WithGroupStartID 设置自动创建消费者组时的起始消费位置, 默认 0-0, 即重放 stream 中已有的全部消息;
+// 设置为 $ 时只消费消费者组创建之后写入的新消息. 仅在开启 WithAutoCreateGroup 时生效
+func WithGroupStartID(id string) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.groupStartID = id
+	}
+}
+
+// WithConsumerMetrics 注入指标采集器
+func WithConsumerMetrics(collector MetricsCollector) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.metrics = collector
+	}
+}
+
+// WithConsumerTracer 注入链路追踪, 执行回调前会从消息中提取 trace 上下文, 并以 topic 为名创建子 span
+func WithConsumerTracer(tracer trace.Tracer) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.tracer = tracer
+	}
+}
+
+// WithManualAck 手动确认模式, 回调函数返回 nil 后不再自动 ack, 由使用方通过 AckerFromContext 获取 Acker 自行确认,
+// 适用于需要在自身事务提交后才确认消息的场景. 未确认的消息会停留在 pending 列表中, 当前 consumer 不会重复投递,
+// consumer 重启或被其他消费者认领后才会再次投递
+func WithManualAck() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.manualAck = true
+	}
+}
+
+// WithNoAck 以 NOACK 方式读取消息, 消息投递后不会进入 pending 列表, 也不需要 ack, 适用于指标, 遥测等允许丢消息的场景.
+// 回调函数执行失败的消息不会重试, 也不会投递到死信队列
+func WithNoAck() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.noAck = true
+	}
+}
+
+// WithAckPolicy 设置自动确认消息的时机, 默认为 AckAfterSuccess(至少一次投递).
+// AckBeforeProcess 在读取后立即 ack(至多一次投递), 处理失败的消息不会重试, 也不会投递到死信队列, 此时 WithManualAck 不再生效
+func WithAckPolicy(policy AckPolicy) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.ackPolicy = policy
+	}
+}
+
+// WithMaxInFlight 限制在途消息数, 手动确认模式(包括 channel 模式)下已交给使用方但尚未 ack 的消息达到 n 条时,
+// 暂停读取新消息直到有消息被 ack; 同时单次最多读取 n 条新消息. 默认不限制
+func WithMaxInFlight(n int) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.maxInFlight = n
+	}
+}
+
+// WithSkipInvalidMsg 读取到格式错误的消息时跳过该消息并记录日志(随后直接 ack), 同一批次的其他消息正常处理.
+// 默认整批读取失败, 适用于与其他语言的生产者共用 stream 的场景
+func WithSkipInvalidMsg() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.skipInvalidMsg = true
+	}
+}
+
+// WithPendingMinIdle 设置 pending 消息被重新认领前的最小空闲时长. 设置后消费者会通过 XPENDING + XCLAIM
+// 认领整个消费者组中空闲超过该时长的消息(即原消费者被认为已经宕机), 而不是每轮都立即重新处理自己名下的 pending 消息
+func WithPendingMinIdle(minIdle time.Duration) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.pendingMinIdle = minIdle
+	}
+}
+
+// WithOnResult 每次执行回调函数后触发 hook, 成功与失败都会触发, 可用于将处理结果记录到审计系统
+func WithOnResult(hook ResultHook) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.onResult = hook
+	}
+}
+
+// WithRetryDecider 根据回调函数返回的 error 决定失败消息是继续重试, 直接投递到死信队列还是直接丢弃,
+// 例如参数校验失败的消息重试也不会成功, 可以直接投递到死信队列. 默认包装了 ErrPoisonMsg 的 error 直接投递到死信队列, 其余按次数重试
+func WithRetryDecider(decider RetryDecider) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.retryDecider = decider
+	}
+}
+
+// WithDropAfterMaxRetry 失败次数达到重试上限的消息直接 ack 丢弃, 不再投递到死信队列, 适用于已知的脏数据.
+// RetryDecider 显式返回 DecisionDeadLetter 的消息仍会投递到死信队列
+func WithDropAfterMaxRetry() ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.dropAfterMaxRetry = true
+	}
+}
+
+// WithRetryBackoff 失败消息按指数退避重试, 第 n 次失败后需要等待 base * 2^(n-1) 才会再次处理, 最长不超过 max.
+// 默认失败消息会在下一轮立即重试, 下游短暂故障时很容易耗尽重试次数而被投递到死信队列
+func WithRetryBackoff(base, max time.Duration) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.retryBackoffBase = base
+		opts.retryBackoffMax = max
+	}
+}
+
+// WithBackoffJitter 为消费者的各类等待时长增加 ±ratio 比例的随机抖动, 包括每轮阻塞读取的时长(即读取 pending 消息的周期)、
+// 失败重试的退避时长与连接池耗尽后的退避时长, 避免大量消费者同时启动后步调一致地访问 pending 列表. ratio 取值范围为 [0, 1], 默认为 0 即不抖动
+func WithBackoffJitter(ratio float64) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.backoffJitter = ratio
+	}
+}
+
+// WithConsumerCodec 设置 Decode 与 TypedConsumer 使用的序列化方式, 默认 JSON
+func WithConsumerCodec(codec Codec) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithConsumerCompression 注册解压算法, 消费者默认支持 gzip, 生产方使用其他算法时需要注册对应的实现
+func WithConsumerCompression(compressor Compressor) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		if opts.compressors == nil {
+			opts.compressors = make(map[string]Compressor)
+		}
+		opts.compressors[compressor.Name()] = compressor
+	}
+}
+
+// WithPendingBatchSize 每轮最多读取 n 条自己名下的 pending 消息, 并记录读取到的位置, 下一轮从该位置之后继续读取,
+// 遍历到末尾后再从头开始, 避免 pending 积压较多时每轮都从头重新读取全部消息. 默认且最多为 1000 条
+func WithPendingBatchSize(n int) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.pendingBatchSize = n
+	}
+}
+
+// WithPoolExhaustedBackoff 接收消息时连接池耗尽, 等待 backoff 后再重新接收, 默认 1s
+func WithPoolExhaustedBackoff(backoff time.Duration) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.poolExhaustedBackoff = backoff
+	}
+}
+
+// WithOnPoolExhausted 接收消息时连接池耗尽触发 hook, 可用于上报告警以排查连接泄漏
+func WithOnPoolExhausted(hook PoolExhaustedHook) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.onPoolExhausted = hook
+	}
+}
+
+// WithOnStopped consumer 因运行期错误自行停止时触发 hook, 用于 ConsumerGroup 时每个停止的成员都会触发一次
+func WithOnStopped(hook StoppedHook) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.onStopped = hook
+	}
+}
+
+// WithConsumerContextFields 执行回调函数前, 将生产方通过 WithProducerContextFields 写入的值还原到 ctx 中
+func WithConsumerContextFields(fields ...ContextField) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.contextFields = append(opts.contextFields, fields...)
+	}
+}
+
+// WithConsumerLogger 设置日志实现, 默认使用 log.GetDefaultLogger(), 传入 log.NewNopLogger() 可以关闭日志,
+// 或者通过 log.NewLogger(log.WithLogLevel("error")) 只保留错误日志
+func WithConsumerLogger(logger log.Logger) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.logger = logger
+	}
+}
+
+// withClock 替换消费者的时间来源, 仅用于测试
+func withClock(c clock) ConsumerOption {
+	return func(opts *ConsumerOptions) {
+		opts.clock = c
+	}
+}
+
 func repairConsumer(opts *ConsumerOptions) {
-	if opts.receiveTimeout < 0 {
+	// BLOCK 0 表示无限阻塞, 只能通过 WithInfiniteBlock 显式开启
+	if opts.receiveTimeout <= 0 {
 		opts.receiveTimeout = 2 * time.Second
 	}
 
-	if opts.maxRetryLimit < 0 {
+	if opts.adaptiveBlockMax > 0 {
+		if opts.adaptiveBlockMin <= 0 {
+			opts.adaptiveBlockMin = 100 * time.Millisecond
+		}
+		if opts.adaptiveBlockMax < opts.adaptiveBlockMin {
+			opts.adaptiveBlockMax = opts.adaptiveBlockMin
+		}
+	}
+
+	// 只有未设置或者设置为负数时才使用默认值, 显式设置为 0 表示不重试
+	if !opts.maxRetryLimitSet || opts.maxRetryLimit < 0 {
 		opts.maxRetryLimit = 3
 	}
 
@@ -85,4 +641,186 @@ func repairConsumer(opts *ConsumerOptions) {
 	if opts.handleMsgTimeout <= 0 {
 		opts.handleMsgTimeout = time.Second
 	}
+
+	// 自动创建消费者组默认从头消费
+	if opts.groupStartID == "" {
+		opts.groupStartID = "0-0"
+	}
+
+	if opts.metrics == nil {
+		opts.metrics = noopMetrics{}
+	}
+
+	if opts.tracer == nil {
+		opts.tracer = trace.NewNoopTracerProvider().Tracer("")
+	}
+
+	if opts.onResult == nil {
+		opts.onResult = func(context.Context, *redis.MsgEntity, error, int) {}
+	}
+
+	if opts.retryDecider == nil {
+		opts.retryDecider = defaultRetryDecider
+	}
+
+	if opts.retryBackoffMax < opts.retryBackoffBase {
+		opts.retryBackoffMax = opts.retryBackoffBase
+	}
+
+	if opts.backoffJitter < 0 {
+		opts.backoffJitter = 0
+	}
+	if opts.backoffJitter > 1 {
+		opts.backoffJitter = 1
+	}
+
+	if opts.codec == nil {
+		opts.codec = JSONCodec{}
+	}
+
+	if opts.compressors == nil {
+		opts.compressors = make(map[string]Compressor)
+	}
+	if _, ok := opts.compressors[GzipCompressor{}.Name()]; !ok {
+		opts.compressors[GzipCompressor{}.Name()] = GzipCompressor{}
+	}
+
+	if opts.maxInFlight < 0 {
+		opts.maxInFlight = 0
+	}
+
+	if opts.pendingBatchSize < 0 {
+		opts.pendingBatchSize = 0
+	}
+
+	if opts.poolExhaustedBackoff <= 0 {
+		opts.poolExhaustedBackoff = time.Second
+	}
+
+	if opts.onPoolExhausted == nil {
+		opts.onPoolExhausted = func(context.Context, redis.PoolStats) {}
+	}
+
+	if opts.onStopped == nil {
+		opts.onStopped = func(string, string, string, error) {}
+	}
+
+	if opts.logger == nil {
+		opts.logger = log.GetDefaultLogger()
+	}
+
+	if opts.clock == nil {
+		opts.clock = realClock{}
+	}
+}
+
+type DelayedPollerOptions struct {
+	// 轮询到期消息的时间间隔
+	pollInterval time.Duration
+	// 单次转移的最大消息条数
+	batchSize int
+	// 日志
+	logger log.Logger
+	// 时间来源
+	clock clock
+}
+
+type DelayedPollerOption func(opts *DelayedPollerOptions)
+
+// WithPollInterval 设置轮询到期消息的时间间隔, 默认 1s
+func WithPollInterval(interval time.Duration) DelayedPollerOption {
+	return func(opts *DelayedPollerOptions) {
+		opts.pollInterval = interval
+	}
+}
+
+// WithPollBatchSize 设置单次转移的最大消息条数, 默认 100
+func WithPollBatchSize(batchSize int) DelayedPollerOption {
+	return func(opts *DelayedPollerOptions) {
+		opts.batchSize = batchSize
+	}
+}
+
+// WithPollLogger 设置日志, 默认使用 log.GetDefaultLogger()
+func WithPollLogger(logger log.Logger) DelayedPollerOption {
+	return func(opts *DelayedPollerOptions) {
+		opts.logger = logger
+	}
+}
+
+func repairDelayedPoller(opts *DelayedPollerOptions) {
+	if opts.pollInterval <= 0 {
+		opts.pollInterval = time.Second
+	}
+
+	if opts.batchSize <= 0 {
+		opts.batchSize = 100
+	}
+
+	if opts.logger == nil {
+		opts.logger = log.GetDefaultLogger()
+	}
+
+	if opts.clock == nil {
+		opts.clock = realClock{}
+	}
+}
+
+type TailConsumerOptions struct {
+	// 单次最多读取的消息条数
+	batchSize int
+	// 每轮阻塞等待新消息的时长
+	blockTimeout time.Duration
+	// 处理单条消息的超时阈值
+	handleMsgTimeout time.Duration
+	// 日志
+	logger log.Logger
+}
+
+type TailConsumerOption func(opts *TailConsumerOptions)
+
+// WithTailBatchSize 设置单次最多读取的消息条数, 默认 100
+func WithTailBatchSize(batchSize int) TailConsumerOption {
+	return func(opts *TailConsumerOptions) {
+		opts.batchSize = batchSize
+	}
+}
+
+// WithTailBlockTimeout 设置每轮阻塞等待新消息(XREAD BLOCK)的时长, 默认 2s
+func WithTailBlockTimeout(timeout time.Duration) TailConsumerOption {
+	return func(opts *TailConsumerOptions) {
+		opts.blockTimeout = timeout
+	}
+}
+
+// WithTailHandleMsgTimeout 设置处理单条消息的超时阈值, 默认 1s
+func WithTailHandleMsgTimeout(timeout time.Duration) TailConsumerOption {
+	return func(opts *TailConsumerOptions) {
+		opts.handleMsgTimeout = timeout
+	}
+}
+
+// WithTailLogger 设置日志, 默认使用 log.GetDefaultLogger()
+func WithTailLogger(logger log.Logger) TailConsumerOption {
+	return func(opts *TailConsumerOptions) {
+		opts.logger = logger
+	}
+}
+
+func repairTailConsumer(opts *TailConsumerOptions) {
+	if opts.batchSize <= 0 {
+		opts.batchSize = 100
+	}
+
+	if opts.blockTimeout <= 0 {
+		opts.blockTimeout = 2 * time.Second
+	}
+
+	if opts.handleMsgTimeout <= 0 {
+		opts.handleMsgTimeout = time.Second
+	}
+
+	if opts.logger == nil {
+		opts.logger = log.GetDefaultLogger()
+	}
 }
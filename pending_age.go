@@ -0,0 +1,52 @@
+package redis_mq
+
+import (
+	"time"
+)
+
+// maxPendingAgeCheckInterval 检查 pending 消息空闲时长的最大间隔
+const maxPendingAgeCheckInterval = 10 * time.Second
+
+// expireStalePending 开启 WithMaxPendingAge 时, 认领消费者组中空闲时长超过上限的 pending 消息,
+// 无论已投递多少次都直接标记为达到重试上限, 由 deliverDeadLetter 投递到死信队列并 ack
+func (c *Consumer) expireStalePending() {
+	if c.opts.maxPendingAge <= 0 || c.opts.noAck {
+		return
+	}
+
+	now := c.opts.clock.Now()
+	interval := c.opts.maxPendingAge
+	if interval > maxPendingAgeCheckInterval {
+		interval = maxPendingAgeCheckInterval
+	}
+	if now.Sub(c.lastPendingAgeCheck) < interval {
+		return
+	}
+	c.lastPendingAgeCheck = now
+
+	entries, err := c.client.XPendingIdle(c.ctx, c.topic, c.groupID, c.opts.maxPendingAge, pendingDetailLimit, "")
+	if err != nil {
+		c.opts.logger.WarnFormat("query stale pending msg failed, topic: %s, err: %v", c.topic, err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	msgIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		msgIDs = append(msgIDs, entry.MsgID)
+	}
+
+	// XCLAIM 会再次校验空闲时长, 避免与其他消费者重复处理
+	staleMsgs, err := c.client.XClaim(c.ctx, c.topic, c.groupID, c.consumerID, c.opts.maxPendingAge, msgIDs...)
+	if err != nil {
+		c.opts.logger.WarnFormat("claim stale pending msg failed, topic: %s, err: %v", c.topic, err)
+		return
+	}
+
+	for _, msg := range staleMsgs {
+		c.opts.logger.WarnFormat("pending msg exceeded max age, msg id: %s, max age: %s", msg.MsgID, c.opts.maxPendingAge)
+		c.recordFailure(msg, DecisionDeadLetter)
+	}
+}
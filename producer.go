@@ -2,16 +2,41 @@ package redis_mq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/time/rate"
 
 	"github.com/bing-bing-student/redis-mq/redis"
 )
 
 type Producer struct {
-	client *redis.Client
+	client ProducerClient
 	opts   *ProducerOptions
+
+	// 限流器, 未设置 WithRateLimit 时为 nil
+	limiter *rate.Limiter
+
+	// closed 为 true 后不再接受新的发送, sending 记录正在进行中的发送
+	closeMu sync.RWMutex
+	closed  bool
+	sending sync.WaitGroup
+	// 关闭 owned client 只执行一次, 重复 Close 时返回第一次关闭的结果
+	clientCloseOnce sync.Once
+	clientCloseErr  error
+
+	// 异步发送的缓冲区, 第一次调用 SendAsync 时启动后台协程
+	asyncOnce sync.Once
+	asyncCh   chan *asyncMsg
+	asyncDone chan struct{}
+	// 缓冲区只能关闭一次
+	asyncCloseOnce sync.Once
 }
 
-func NewProducer(client *redis.Client, opts ...ProducerOption) *Producer {
+func NewProducer(client ProducerClient, opts ...ProducerOption) *Producer {
 	p := Producer{
 		client: client,
 		opts:   &ProducerOptions{},
@@ -23,10 +48,240 @@ func NewProducer(client *redis.Client, opts ...ProducerOption) *Producer {
 
 	repairProducer(p.opts)
 
+	if p.opts.rateLimit > 0 {
+		p.limiter = rate.NewLimiter(rate.Limit(p.opts.rateLimit), p.opts.rateLimit)
+	}
+
 	return &p
 }
 
+// ErrMsgTooLarge 消息大小超过 WithMaxMsgBytes 设置的上限
+var ErrMsgTooLarge = errors.New("message size exceeds limit")
+
+// ErrProducerClosed 生产者已经关闭
+var ErrProducerClosed = errors.New("producer closed")
+
+// Close 关闭生产者, 之后的发送都会返回 ErrProducerClosed. 会等待进行中的发送完成, 并写入 SendAsync 缓冲区中剩余的消息,
+// ctx 结束时不再等待并返回 ctx.Err(). 设置了 WithOwnedClient 时同时关闭 redis 客户端.
+// 可以重复调用, 之前因 ctx 结束而未完成的等待与关闭会重新进行
+func (p *Producer) Close(ctx context.Context) error {
+	p.closeMu.Lock()
+	p.closed = true
+	p.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.sending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	// 此时已经没有进行中的 SendAsync, 可以安全地关闭缓冲区
+	if err := p.closeAsync(ctx); err != nil {
+		return err
+	}
+
+	return p.closeClient()
+}
+
+// closeClient 设置了 WithOwnedClient 时关闭 redis 客户端, 只会关闭一次
+func (p *Producer) closeClient() error {
+	if !p.opts.ownedClient {
+		return nil
+	}
+
+	p.clientCloseOnce.Do(func() {
+		if closer, ok := p.client.(interface{ Close() error }); ok {
+			p.clientCloseErr = closer.Close()
+		}
+	})
+	return p.clientCloseErr
+}
+
+// beginSend 登记一次发送, 生产者已经关闭时返回 ErrProducerClosed, 登记成功后需要调用 p.sending.Done
+func (p *Producer) beginSend() error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	if p.closed {
+		return ErrProducerClosed
+	}
+	p.sending.Add(1)
+	return nil
+}
+
+// SendResult 发送消息的结果
+type SendResult struct {
+	// ID 消息 ID
+	ID string
+	// Trimmed 本次写入时因裁剪被删除的消息条数, 仅 SendMsgWithStats 会统计
+	Trimmed int64
+}
+
+// sendOptions 单次发送的参数
+type sendOptions struct {
+	// 是否统计本次写入时裁剪掉的消息条数
+	withStats bool
+	// 本次写入按长度裁剪时保留的消息条数, 为 0 时使用生产者的配置
+	maxLen int
+	// 是否为重放的消息, 重放时原样使用 headers, 不再压缩、注入 trace 与 context 字段, 也不做幂等校验
+	replay  bool
+	headers map[string]string
+}
+
 // SendMsg 生产一条消息
 func (p *Producer) SendMsg(ctx context.Context, topic, key, val string) (string, error) {
-	return p.client.XAddMsg(ctx, topic, p.opts.msgQueueLen, key, val)
+	result, err := p.send(ctx, topic, key, val, sendOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// SendMsgWithStats 生产一条消息, 同时返回本次写入时因裁剪被删除的消息条数, 可用于监控消息的淘汰情况.
+// 统计通过 lua 脚本在 XADD 前后执行 XLEN 实现, 开销略高于 SendMsg. 幂等发送命中已有消息时 Trimmed 为 0
+func (p *Producer) SendMsgWithStats(ctx context.Context, topic, key, val string) (*SendResult, error) {
+	return p.send(ctx, topic, key, val, sendOptions{withStats: true})
+}
+
+// SendMsgWithMaxLen 生产一条消息, 本次写入按 maxLen 裁剪 topic, 覆盖 WithMsgQueueLen 与 WithMinIDRetention 的配置,
+// 适用于一个生产者向多个保留策略不同的 topic 发送消息. maxLen 不大于 0 时使用生产者的配置
+func (p *Producer) SendMsgWithMaxLen(ctx context.Context, topic, key, val string, maxLen int) (string, error) {
+	result, err := p.send(ctx, topic, key, val, sendOptions{maxLen: maxLen})
+	if err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// send 校验, 限流后发送消息
+func (p *Producer) send(ctx context.Context, topic, key, val string, so sendOptions) (*SendResult, error) {
+	if err := p.beginSend(); err != nil {
+		return nil, err
+	}
+	defer p.sending.Done()
+
+	if err := p.checkMsgSize(key, val); err != nil {
+		return nil, err
+	}
+
+	if p.limiter != nil {
+		if err := p.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, span := p.startSpan(ctx, topic)
+	defer span.End()
+
+	var result *SendResult
+	var err error
+	if p.opts.idempotencyKeyFunc != nil && !so.replay {
+		result, err = p.sendIdempotent(ctx, topic, key, val, so)
+	} else {
+		result, err = p.xAdd(ctx, topic, key, val, so)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	p.opts.metrics.IncProduced(topic)
+	return result, nil
+}
+
+// SendValue 将 val 按照 WithProducerCodec 指定的方式序列化后生产一条消息
+func (p *Producer) SendValue(ctx context.Context, topic, key string, val interface{}) (string, error) {
+	data, err := p.opts.codec.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+
+	return p.SendMsg(ctx, topic, key, string(data))
+}
+
+// checkMsgSize 校验消息大小是否超过上限
+func (p *Producer) checkMsgSize(key, val string) error {
+	if p.opts.maxMsgBytes > 0 && len(key)+len(val) > p.opts.maxMsgBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d bytes", ErrMsgTooLarge, len(key)+len(val), p.opts.maxMsgBytes)
+	}
+	return nil
+}
+
+// xAdd 将消息写入 stream
+func (p *Producer) xAdd(ctx context.Context, topic, key, val string, so sendOptions) (*SendResult, error) {
+	val, xAddArgs, err := p.xAddArgs(ctx, val, so)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := p.xAddOnce(ctx, topic, xAddArgs, key, val, so)
+		if err == nil || attempt >= p.opts.sendAttempts || !redis.IsTransientErr(err) {
+			return result, err
+		}
+
+		p.opts.logger.WarnFormat("send msg failed, topic: %s, attempt: %d, err: %v", topic, attempt, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(p.opts.sendRetryBackoff):
+		}
+	}
+}
+
+// xAddArgs 压缩 val 并组装 XADD 的参数, 附加字段中包含 ctx 中的链路追踪与 WithProducerContextFields 的值
+func (p *Producer) xAddArgs(ctx context.Context, val string, so sendOptions) (string, redis.XAddArgs, error) {
+	headers := so.headers
+	if !so.replay {
+		var err error
+		val, headers, err = p.compress(val, p.injectContextHeaders(ctx, injectTraceHeaders(ctx)))
+		if err != nil {
+			return "", redis.XAddArgs{}, err
+		}
+	}
+
+	trim := p.trimStrategy(so)
+	return val, redis.XAddArgs{
+		Trim:       &trim,
+		NoMkStream: p.opts.noMkStream,
+		Headers:    headers,
+	}, nil
+}
+
+// xAddOnce 执行一次 XADD, 需要统计裁剪条数时通过 lua 脚本写入
+func (p *Producer) xAddOnce(ctx context.Context, topic string, xAddArgs redis.XAddArgs, key, val string, so sendOptions) (*SendResult, error) {
+	if so.withStats {
+		result, err := p.client.XAddMsgWithStats(ctx, topic, xAddArgs, key, val)
+		if err != nil {
+			return nil, err
+		}
+		return &SendResult{ID: result.ID, Trimmed: result.Trimmed}, nil
+	}
+
+	msgID, err := p.client.XAddMsgWithArgs(ctx, topic, xAddArgs, key, val)
+	if err != nil {
+		return nil, err
+	}
+	return &SendResult{ID: msgID}, nil
+}
+
+// 根据配置得到本次发送使用的裁剪策略, 单次发送指定的 maxLen 优先
+func (p *Producer) trimStrategy(so sendOptions) redis.TrimStrategy {
+	if so.maxLen > 0 {
+		return redis.TrimByMaxLen(int64(so.maxLen), p.opts.approxTrim)
+	}
+	if p.opts.minIDFunc != nil {
+		// minIDFunc 返回空时退化为按长度裁剪, 避免 MAXLEN 0 清空 stream
+		if minID := p.opts.minIDFunc(); minID != "" {
+			return redis.TrimByMinID(minID, p.opts.approxTrim)
+		}
+	}
+	return redis.TrimByMaxLen(int64(p.opts.msgQueueLen), p.opts.approxTrim)
 }
@@ -0,0 +1,198 @@
+package redis_mq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	redis_mq "github.com/bing-bing-student/redis-mq"
+	"github.com/bing-bing-student/redis-mq/log"
+	"github.com/bing-bing-student/redis-mq/mqtest"
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+func TestProducerSendMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []redis_mq.ProducerOption
+		key, val string
+		wantErr  error
+		wantTrim redis.TrimStrategy
+	}{
+		{
+			name:     "default trim",
+			key:      "k",
+			val:      "v",
+			wantTrim: redis.TrimByMaxLen(500, false),
+		},
+		{
+			name:     "approx max len",
+			opts:     []redis_mq.ProducerOption{redis_mq.WithMsgQueueLen(10), redis_mq.WithApproxTrim()},
+			key:      "k",
+			val:      "v",
+			wantTrim: redis.TrimByMaxLen(10, true),
+		},
+		{
+			name:     "min id retention",
+			opts:     []redis_mq.ProducerOption{redis_mq.WithMinIDRetention(func() string { return "5-0" })},
+			key:      "k",
+			val:      "v",
+			wantTrim: redis.TrimByMinID("5-0", false),
+		},
+		{
+			name:     "empty min id falls back to max len",
+			opts:     []redis_mq.ProducerOption{redis_mq.WithMinIDRetention(func() string { return "" })},
+			key:      "k",
+			val:      "v",
+			wantTrim: redis.TrimByMaxLen(500, false),
+		},
+		{
+			name:     "binary val",
+			key:      "k",
+			val:      "\x00\xff{\"",
+			wantTrim: redis.TrimByMaxLen(500, false),
+		},
+		{
+			name:    "too large",
+			opts:    []redis_mq.ProducerOption{redis_mq.WithMaxMsgBytes(4)},
+			key:     "k",
+			val:     "long val",
+			wantErr: redis_mq.ErrMsgTooLarge,
+		},
+		{
+			name:     "within size limit",
+			opts:     []redis_mq.ProducerOption{redis_mq.WithMaxMsgBytes(4)},
+			key:      "k",
+			val:      "val",
+			wantTrim: redis.TrimByMaxLen(500, false),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mqtest.NewFakeClient()
+			opts := append([]redis_mq.ProducerOption{redis_mq.WithProducerLogger(log.NewNopLogger())}, tt.opts...)
+			producer := redis_mq.NewProducer(client, opts...)
+
+			id, err := producer.SendMsg(context.Background(), "topic", tt.key, tt.val)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if msgs := client.Msgs(); len(msgs) != 0 {
+					t.Fatalf("msgs = %v, want none", msgs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("send msg: %v", err)
+			}
+
+			msgs := client.TopicMsgs("topic")
+			if len(msgs) != 1 {
+				t.Fatalf("msgs = %v, want 1 msg", msgs)
+			}
+			msg := msgs[0]
+			if msg.ID != id || msg.Key != tt.key || msg.Val != tt.val {
+				t.Fatalf("msg = %+v, want id %s key %q val %q", msg, id, tt.key, tt.val)
+			}
+			if msg.Trim == nil || *msg.Trim != tt.wantTrim {
+				t.Fatalf("trim = %+v, want %+v", msg.Trim, tt.wantTrim)
+			}
+		})
+	}
+}
+
+func TestProducerSendMsgIdempotent(t *testing.T) {
+	keyFunc := func(key, _ string) string {
+		if key == "" {
+			return ""
+		}
+		return "idem:" + key
+	}
+
+	tests := []struct {
+		name string
+		// 发送前已存在的幂等 key 的值
+		existing map[string]string
+		sends    []string
+		wantErr  error
+		wantMsgs int
+	}{
+		{
+			name:     "duplicate key sent once",
+			sends:    []string{"a", "a"},
+			wantMsgs: 1,
+		},
+		{
+			name:     "different keys",
+			sends:    []string{"a", "b"},
+			wantMsgs: 2,
+		},
+		{
+			name:     "empty idempotency key not deduplicated",
+			sends:    []string{"", ""},
+			wantMsgs: 2,
+		},
+		{
+			name:     "existing msg id returned",
+			existing: map[string]string{"idem:a": "9-0"},
+			sends:    []string{"a"},
+			wantMsgs: 0,
+		},
+		{
+			name:     "pending placeholder",
+			existing: map[string]string{"idem:a": ""},
+			sends:    []string{"a"},
+			wantErr:  redis_mq.ErrIdempotencyKeyPending,
+			wantMsgs: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := mqtest.NewFakeClient()
+			for key, value := range tt.existing {
+				if _, err := client.SetEX(ctx, key, value, 60); err != nil {
+					t.Fatalf("set existing key: %v", err)
+				}
+			}
+			producer := redis_mq.NewProducer(client,
+				redis_mq.WithProducerLogger(log.NewNopLogger()),
+				redis_mq.WithIdempotencyKey(keyFunc))
+
+			ids := make(map[string]string)
+			for _, key := range tt.sends {
+				id, err := producer.SendMsg(ctx, "topic", key, "val")
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, want %v", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("send msg: %v", err)
+				}
+				if prev, ok := ids[key]; ok && key != "" && prev != id {
+					t.Fatalf("duplicate key %q got id %s, want %s", key, id, prev)
+				}
+				if existing, ok := tt.existing[keyFunc(key, "")]; ok && existing != id {
+					t.Fatalf("key %q got id %s, want stored id %s", key, id, existing)
+				}
+				ids[key] = id
+
+				// 发送成功后幂等 key 中记录消息 ID
+				if key != "" {
+					if stored, err := client.Get(ctx, keyFunc(key, "")); err != nil || stored != id {
+						t.Fatalf("stored id = %q, %v, want %s", stored, err, id)
+					}
+				}
+			}
+
+			if got := len(client.Msgs()); got != tt.wantMsgs {
+				t.Fatalf("msgs = %d, want %d", got, tt.wantMsgs)
+			}
+		})
+	}
+}
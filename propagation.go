@@ -0,0 +1,44 @@
+package redis_mq
+
+import (
+	"context"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// contextHeaderPrefix 透传的 ctx 值在消息附加字段中的前缀
+const contextHeaderPrefix = "x-ctx-"
+
+// ContextField 需要在生产者与消费者之间透传的 ctx 值, Key 为 ctx 中的 key, Field 为消息附加字段名(不含前缀).
+// 只透传 string 类型的值, 例如 request id
+type ContextField struct {
+	Key   interface{}
+	Field string
+}
+
+// injectContextHeaders 将 ctx 中需要透传的值写入消息附加字段
+func (p *Producer) injectContextHeaders(ctx context.Context, headers map[string]string) map[string]string {
+	for _, field := range p.opts.contextFields {
+		val, ok := ctx.Value(field.Key).(string)
+		if !ok || val == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string, len(p.opts.contextFields))
+		}
+		headers[contextHeaderPrefix+field.Field] = val
+	}
+
+	return headers
+}
+
+// restoreContextValues 将消息附加字段中透传的值还原到回调函数的 ctx 中
+func (c *Consumer) restoreContextValues(ctx context.Context, msg *redis.MsgEntity) context.Context {
+	for _, field := range c.opts.contextFields {
+		if val, ok := msg.Headers[contextHeaderPrefix+field.Field]; ok {
+			ctx = context.WithValue(ctx, field.Key, val)
+		}
+	}
+
+	return ctx
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// claimAndTagScript 原子地认领空闲超时的 pending 消息, 并在 hash 中记录每条消息的认领者
+// KEYS[1]: stream, KEYS[2]: 记录认领者的 hash
+// ARGV[1]: 消费者组, ARGV[2]: 消费者, ARGV[3]: 最小空闲时长(毫秒), ARGV[4]: 单次认领的最大条数
+var claimAndTagScript = NewScript(`
+local reply = redis.call('XAUTOCLAIM', KEYS[1], ARGV[1], ARGV[2], ARGV[3], '0-0', 'COUNT', ARGV[4])
+local claimed = {}
+for _, entry in ipairs(reply[2]) do
+	if entry then
+		redis.call('HSET', KEYS[2], entry[1], ARGV[2])
+		table.insert(claimed, entry)
+	end
+end
+return claimed
+`)
+
+// ClaimAndTag 通过 lua 脚本在一次服务端调用中完成 XAUTOCLAIM 认领与认领者标记,
+// 避免多个消费者同时恢复同一批积压消息时产生竞争. tagKey 为记录 消息ID -> 认领者 的 hash,
+// 集群模式下 tagKey 需要与 topic 位于同一个 slot(例如使用 hash tag)
+func (c *Client) ClaimAndTag(ctx context.Context, topic, groupID, consumerID, tagKey string, minIdle time.Duration, count int) ([]*MsgEntity, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis ClaimAndTag: %w", ErrEmptyTopic)
+	}
+	if groupID == "" || consumerID == "" || tagKey == "" {
+		return nil, errors.New("redis ClaimAndTag group_id | consumer_id | tag_key can't be empty")
+	}
+	if count <= 0 {
+		return nil, errors.New("redis ClaimAndTag count must be positive")
+	}
+
+	reply, err := redis.Values(c.EvalScript(ctx, claimAndTagScript, []string{topic, tagKey},
+		[]interface{}{groupID, consumerID, minIdle.Milliseconds(), count}))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMsgEntities(topic, reply, c.options.fieldNames, nil)
+}
@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HSet 设置 hash 中 field 的值, 返回新增的 field 个数
+func (c *Client) HSet(ctx context.Context, key, field, value string) (int64, error) {
+	if key == "" {
+		return -1, fmt.Errorf("redis HSET: %w", ErrEmptyKey)
+	}
+	if field == "" {
+		return -1, errors.New("redis HSET field can't be empty")
+	}
+
+	return redis.Int64(c.do(ctx, "HSET", key, field, value))
+}
+
+// HGet 获取 hash 中 field 的值
+func (c *Client) HGet(ctx context.Context, key, field string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("redis HGET: %w", ErrEmptyKey)
+	}
+	if field == "" {
+		return "", errors.New("redis HGET field can't be empty")
+	}
+
+	return redis.String(c.do(ctx, "HGET", key, field))
+}
+
+// HGetAll 获取 hash 中所有的 field 及其值, key 不存在时返回空 map
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if key == "" {
+		return nil, fmt.Errorf("redis HGETALL: %w", ErrEmptyKey)
+	}
+
+	return redis.StringMap(c.do(ctx, "HGETALL", key))
+}
+
+// HDel 删除 hash 中的 field, 返回实际删除的 field 个数
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	if key == "" {
+		return -1, fmt.Errorf("redis HDEL: %w", ErrEmptyKey)
+	}
+	if len(fields) == 0 {
+		return -1, errors.New("redis HDEL fields can't be empty")
+	}
+
+	args := make([]interface{}, 0, 1+len(fields))
+	args = append(args, key)
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	return redis.Int64(c.do(ctx, "HDEL", args...))
+}
@@ -0,0 +1,255 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/demdxx/gocast"
+	"github.com/gomodule/redigo/redis"
+)
+
+// StreamInfo XINFO STREAM 的返回信息
+type StreamInfo struct {
+	// Length stream 中的消息条数
+	Length int64
+	// RadixTreeKeys 底层 radix tree 的 key 数量
+	RadixTreeKeys int64
+	// RadixTreeNodes 底层 radix tree 的节点数量
+	RadixTreeNodes int64
+	// Groups 消费者组数量
+	Groups int64
+	// LastGeneratedID 最后生成的消息 ID
+	LastGeneratedID string
+	// EntriesAdded 累计写入的消息条数(redis 7.0+), 不支持时为 -1
+	EntriesAdded int64
+	// FirstEntryID 第一条消息的 ID, stream 为空时为空字符串
+	FirstEntryID string
+	// LastEntryID 最后一条消息的 ID, stream 为空时为空字符串
+	LastEntryID string
+}
+
+// GroupInfo XINFO GROUPS 返回的单个消费者组信息
+type GroupInfo struct {
+	// Name 消费者组名称
+	Name string
+	// Consumers 消费者数量
+	Consumers int64
+	// Pending 已投递但未确认的消息条数
+	Pending int64
+	// LastDeliveredID 最后投递的消息 ID
+	LastDeliveredID string
+	// EntriesRead 消费者组已读取的消息条数(redis 7.0+), 不支持时为 -1
+	EntriesRead int64
+	// Lag 尚未投递给消费者组的消息条数(redis 7.0+), 未知时为 -1
+	Lag int64
+}
+
+// ConsumerInfo XINFO CONSUMERS 返回的单个消费者信息
+type ConsumerInfo struct {
+	// Name 消费者名称
+	Name string
+	// Pending 已投递给该消费者但未确认的消息条数
+	Pending int64
+	// Idle 距离该消费者上一次尝试交互的时长
+	Idle time.Duration
+	// Inactive 距离该消费者上一次成功交互的时长(redis 7.2+), 不支持时为 -1
+	Inactive time.Duration
+}
+
+// XInfoStream 查询 stream 的元信息
+func (c *Client) XInfoStream(ctx context.Context, topic string) (*StreamInfo, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis XINFO STREAM: %w", ErrEmptyTopic)
+	}
+
+	fields, err := infoFields(c.do(ctx, "XINFO", "STREAM", topic))
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamInfo{
+		Length:          gocast.ToInt64(fields["length"]),
+		RadixTreeKeys:   gocast.ToInt64(fields["radix-tree-keys"]),
+		RadixTreeNodes:  gocast.ToInt64(fields["radix-tree-nodes"]),
+		Groups:          gocast.ToInt64(fields["groups"]),
+		LastGeneratedID: gocast.ToString(fields["last-generated-id"]),
+		EntriesAdded:    infoInt64(fields, "entries-added"),
+		FirstEntryID:    infoEntryID(fields["first-entry"]),
+		LastEntryID:     infoEntryID(fields["last-entry"]),
+	}, nil
+}
+
+// XInfoGroups 查询 stream 下所有消费者组的信息
+func (c *Client) XInfoGroups(ctx context.Context, topic string) ([]*GroupInfo, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis XINFO GROUPS: %w", ErrEmptyTopic)
+	}
+
+	reply, err := redis.Values(c.do(ctx, "XINFO", "GROUPS", topic))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*GroupInfo, 0, len(reply))
+	for _, rawGroup := range reply {
+		fields, err := infoFields(rawGroup, nil)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, &GroupInfo{
+			Name:            gocast.ToString(fields["name"]),
+			Consumers:       gocast.ToInt64(fields["consumers"]),
+			Pending:         gocast.ToInt64(fields["pending"]),
+			LastDeliveredID: gocast.ToString(fields["last-delivered-id"]),
+			EntriesRead:     infoInt64(fields, "entries-read"),
+			Lag:             infoInt64(fields, "lag"),
+		})
+	}
+
+	return groups, nil
+}
+
+// XInfoConsumers 查询消费者组下所有消费者的信息
+func (c *Client) XInfoConsumers(ctx context.Context, topic, group string) ([]*ConsumerInfo, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis XINFO CONSUMERS: %w", ErrEmptyTopic)
+	}
+	if group == "" {
+		return nil, errors.New("redis XINFO CONSUMERS group can't be empty")
+	}
+
+	reply, err := redis.Values(c.do(ctx, "XINFO", "CONSUMERS", topic, group))
+	if err != nil {
+		return nil, err
+	}
+
+	consumers := make([]*ConsumerInfo, 0, len(reply))
+	for _, rawConsumer := range reply {
+		fields, err := infoFields(rawConsumer, nil)
+		if err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, &ConsumerInfo{
+			Name:     gocast.ToString(fields["name"]),
+			Pending:  gocast.ToInt64(fields["pending"]),
+			Idle:     time.Duration(gocast.ToInt64(fields["idle"])) * time.Millisecond,
+			Inactive: time.Duration(infoInt64(fields, "inactive")) * time.Millisecond,
+		})
+	}
+
+	return consumers, nil
+}
+
+// infoFields 将 XINFO 返回的 key/value 平铺数组转换为 map
+func infoFields(reply interface{}, err error) (map[string]interface{}, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid xinfo reply format")
+	}
+
+	fields := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		fields[gocast.ToString(values[i])] = values[i+1]
+	}
+
+	return fields, nil
+}
+
+// infoInt64 读取整型字段, 字段不存在或为 nil 时返回 -1
+func infoInt64(fields map[string]interface{}, key string) int64 {
+	val, ok := fields[key]
+	if !ok || val == nil {
+		return -1
+	}
+	return gocast.ToInt64(val)
+}
+
+// infoEntryID 从 first-entry / last-entry 中取出消息 ID
+func infoEntryID(rawEntry interface{}) string {
+	entry, _ := rawEntry.([]interface{})
+	if len(entry) == 0 {
+		return ""
+	}
+	return gocast.ToString(entry[0])
+}
+
+// ErrLagUnknown 低版本 redis 无法直接获取积压量, 且积压超过 lagCountLimit 时返回
+var ErrLagUnknown = errors.New("consumer group lag unknown")
+
+const (
+	// lagPageSize 计算积压量时每次 XRANGE 读取的条数
+	lagPageSize = 1000
+	// lagCountLimit 计算积压量时最多遍历的消息条数
+	lagCountLimit = 10 * lagPageSize
+)
+
+// GroupLag 返回消费者组的积压量, 即 stream 中尚未投递给该消费者组的消息条数.
+// redis 7.0+ 直接使用 XINFO GROUPS 的 lag 字段, 低版本或 lag 未知时从最后投递的消息 ID 开始分页统计,
+// 积压超过 lagCountLimit 条时返回 ErrLagUnknown
+func (c *Client) GroupLag(ctx context.Context, topic, group string) (int64, error) {
+	if topic == "" {
+		return -1, fmt.Errorf("redis GroupLag: %w", ErrEmptyTopic)
+	}
+	if group == "" {
+		return -1, errors.New("redis GroupLag group can't be empty")
+	}
+
+	groups, err := c.XInfoGroups(ctx, topic)
+	if err != nil {
+		return -1, err
+	}
+
+	var groupInfo *GroupInfo
+	for _, g := range groups {
+		if g.Name == group {
+			groupInfo = g
+			break
+		}
+	}
+	if groupInfo == nil {
+		return -1, fmt.Errorf("redis consumer group not found: %s", group)
+	}
+	if groupInfo.Lag >= 0 {
+		return groupInfo.Lag, nil
+	}
+
+	lastDelivered, err := NewStreamID(groupInfo.LastDeliveredID)
+	if err != nil {
+		return -1, err
+	}
+
+	return c.countAfter(ctx, topic, lastDelivered)
+}
+
+// countAfter 分页统计 stream 中 ID 大于 after 的消息条数, 超过 lagCountLimit 条时返回 ErrLagUnknown
+func (c *Client) countAfter(ctx context.Context, topic string, after StreamID) (int64, error) {
+	var count int64
+	for {
+		start := StreamID{Ms: after.Ms, Seq: after.Seq + 1}
+		entries, err := redis.Values(c.do(ctx, "XRANGE", topic, start.String(), "+", "COUNT", lagPageSize))
+		if err != nil {
+			return -1, err
+		}
+
+		count += int64(len(entries))
+		if len(entries) < lagPageSize {
+			return count, nil
+		}
+		if count >= lagCountLimit {
+			return -1, fmt.Errorf("redis GroupLag more than %d msgs behind: %w", lagCountLimit, ErrLagUnknown)
+		}
+
+		entry, err := redis.Values(entries[len(entries)-1], nil)
+		if err != nil || len(entry) == 0 {
+			return -1, errors.New("invalid xrange reply format")
+		}
+		if after, err = NewStreamID(gocast.ToString(entry[0])); err != nil {
+			return -1, err
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// keyspaceAllClasses notify-keyspace-events 中 A 所代表的事件类型
+const keyspaceAllClasses = "g$lshzxetd"
+
+// KeyspaceChannel 返回 key 的 keyspace 通知 channel. 使用 NewClientWithPool 时需要通过 WithDatabase 指定连接池所用的 db
+func (c *Client) KeyspaceChannel(key string) string {
+	return fmt.Sprintf("__keyspace@%d__:%s", c.options.database, key)
+}
+
+// KeyspaceEventsEnabled 查询服务端是否开启了 classes 中所有类型的 keyspace 通知, 例如 stream 事件为 "t".
+// 服务端禁用了 CONFIG 命令时返回 error
+func (c *Client) KeyspaceEventsEnabled(ctx context.Context, classes string) (bool, error) {
+	reply, err := redis.Strings(c.do(ctx, "CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil {
+		return false, err
+	}
+	if len(reply) != 2 {
+		return false, fmt.Errorf("redis CONFIG GET notify-keyspace-events: unexpected reply length %d", len(reply))
+	}
+
+	flags := reply[1]
+	if !strings.Contains(flags, "K") {
+		return false, nil
+	}
+	for _, class := range classes {
+		if strings.ContainsRune(flags, class) {
+			continue
+		}
+		if strings.Contains(flags, "A") && strings.ContainsRune(keyspaceAllClasses, class) {
+			continue
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrLockNotHeld 锁已过期或已被其他持有者获取
+var ErrLockNotHeld = errors.New("lock not held")
+
+// releaseLockScript 仅当 token 匹配时才删除锁, 避免误删其他持有者的锁
+var releaseLockScript = NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// renewLockScript 仅当 token 匹配时才延长锁的过期时间
+var renewLockScript = NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Lock 基于 SET NX EX 实现的分布式锁
+type Lock struct {
+	client *Client
+	key    string
+	token  string
+}
+
+// AcquireLock 尝试获取分布式锁, 锁已被其他持有者占用时返回 false. ttl 以秒为单位生效, 不足一秒按一秒处理
+func (c *Client) AcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error) {
+	if key == "" {
+		return nil, false, fmt.Errorf("redis lock: %w", ErrEmptyKey)
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = c.SetNEX(ctx, key, token, lockTTLSeconds(ttl))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &Lock{
+		client: c,
+		key:    key,
+		token:  token,
+	}, true, nil
+}
+
+// Key 返回锁对应的 key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Release 释放锁, 锁已过期或已被其他持有者获取时返回 ErrLockNotHeld
+func (l *Lock) Release(ctx context.Context) error {
+	reply, err := redis.Int64(l.client.EvalScript(ctx, releaseLockScript, []string{l.key}, []interface{}{l.token}))
+	if err != nil {
+		return err
+	}
+	if reply == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// Renew 延长锁的过期时间, 锁已过期或已被其他持有者获取时返回 ErrLockNotHeld
+func (l *Lock) Renew(ctx context.Context, ttl time.Duration) error {
+	reply, err := redis.Int64(l.client.EvalScript(ctx, renewLockScript, []string{l.key},
+		[]interface{}{l.token, lockTTLSeconds(ttl) * 1000}))
+	if err != nil {
+		return err
+	}
+	if reply == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// newLockToken 生成随机的锁持有者标识
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// lockTTLSeconds 将 ttl 向上取整为秒
+func lockTTLSeconds(ttl time.Duration) int64 {
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return seconds
+}
@@ -1,5 +1,11 @@
 package redis
 
+import (
+	"crypto/tls"
+	"regexp"
+	"time"
+)
+
 const (
 	// DefaultIdleTimeoutSeconds 默认连接池超过 10s 释放连接
 	DefaultIdleTimeoutSeconds = 10
@@ -18,6 +24,30 @@ type ClientOptions struct {
 	network            string
 	address            string
 	password           string
+	username           string
+	database           int
+	useTLS             bool
+	tlsConfig          *tls.Config
+	maxConnLifetime    time.Duration
+	connectTimeout     time.Duration
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
+	fieldNames         FieldNames
+	topicPattern       *regexp.Regexp
+	maxTopicLen        int
+}
+
+// FieldNames 消息在 stream 中使用的固定字段名
+type FieldNames struct {
+	// Key 存放消息 key 的字段名
+	Key string
+	// Val 存放消息 val 的字段名
+	Val string
+}
+
+// fixed 是否使用固定字段名, 未设置时消息以 key 作为字段名, val 作为字段值写入
+func (n FieldNames) fixed() bool {
+	return n.Key != "" && n.Val != ""
 }
 
 type ClientOption func(c *ClientOptions)
@@ -50,6 +80,74 @@ func WithWaitMode() ClientOption {
 	}
 }
 
+// WithMaxConnLifetime 设置连接的最长存活时间, 超过后连接会被关闭并重建, 为 0 时不限制
+func WithMaxConnLifetime(d time.Duration) ClientOption {
+	return func(c *ClientOptions) {
+		c.maxConnLifetime = d
+	}
+}
+
+// WithConnectTimeout 设置建立连接的超时时间
+func WithConnectTimeout(timeout time.Duration) ClientOption {
+	return func(c *ClientOptions) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithReadTimeout 设置读取单个响应的超时时间, 需要大于阻塞读取消息(BLOCK)的时长
+func WithReadTimeout(timeout time.Duration) ClientOption {
+	return func(c *ClientOptions) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout 设置写入单个命令的超时时间
+func WithWriteTimeout(timeout time.Duration) ClientOption {
+	return func(c *ClientOptions) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithUsername 设置 ACL 用户名(redis 6.0+)
+func WithUsername(username string) ClientOption {
+	return func(c *ClientOptions) {
+		c.username = username
+	}
+}
+
+// WithDatabase 设置连接使用的 db
+func WithDatabase(database int) ClientOption {
+	return func(c *ClientOptions) {
+		c.database = database
+	}
+}
+
+// WithTLS 使用 TLS 连接, tlsConfig 为 nil 时使用默认配置
+func WithTLS(tlsConfig *tls.Config) ClientOption {
+	return func(c *ClientOptions) {
+		c.useTLS = true
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithFieldNames 消息以固定字段名写入 stream, 即 XADD topic * keyField key valField val [附加字段...], 使 stream 中的消息具有统一的结构,
+// 例如 WithFieldNames("key", "data"). 读取时按字段名解析, 不包含固定字段的旧格式消息仍可正常读取.
+// 未设置时沿用以 key 作为字段名, val 作为字段值的格式. 生产者与消费者需要使用相同的配置, 任意一个字段名为空时不生效
+func WithFieldNames(keyField, valField string) ClientOption {
+	return func(c *ClientOptions) {
+		c.fieldNames = FieldNames{Key: keyField, Val: valField}
+	}
+}
+
+// WithTopicValidation 写入消息与创建消费者组前校验 topic, 不符合 pattern 或超过 maxLen 字节时返回 ErrInvalidTopic.
+// pattern 为 nil 或 maxLen 不大于 0 时不做对应的校验. 未设置时只拒绝包含空白与控制字符的 topic
+func WithTopicValidation(pattern *regexp.Regexp, maxLen int) ClientOption {
+	return func(c *ClientOptions) {
+		c.topicPattern = pattern
+		c.maxTopicLen = maxLen
+	}
+}
+
 func repairClient(c *ClientOptions) {
 	if c.maxIdle < 0 {
 		c.maxIdle = DefaultMaxIdle
@@ -62,4 +160,12 @@ func repairClient(c *ClientOptions) {
 	if c.maxActive < 0 {
 		c.maxActive = DefaultMaxActive
 	}
+
+	if c.maxConnLifetime < 0 {
+		c.maxConnLifetime = 0
+	}
+
+	if !c.fieldNames.fixed() || c.fieldNames.Key == c.fieldNames.Val {
+		c.fieldNames = FieldNames{}
+	}
 }
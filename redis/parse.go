@@ -0,0 +1,152 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/demdxx/gocast"
+)
+
+// ErrInvalidMsgFormat stream 返回的消息格式不符合预期
+var ErrInvalidMsgFormat = errors.New("invalid msg format")
+
+// MsgFormatError 解析 stream 消息失败的详细信息, 可以通过 errors.Is(err, ErrInvalidMsgFormat) 判断
+type MsgFormatError struct {
+	// Topic 读取的 stream
+	Topic string
+	// Index 格式错误的消息在本次回复中的下标, 回复整体格式错误时为 -1
+	Index int
+	// MsgID 格式错误的消息 id, 无法解析时为空
+	MsgID string
+	// Shape 原始回复的结构, 例如 array(2)[bulk, array(3)]
+	Shape string
+}
+
+func (e *MsgFormatError) Error() string {
+	return fmt.Sprintf("%v, topic: %s, index: %d, msg id: %s, shape: %s", ErrInvalidMsgFormat, e.Topic, e.Index, e.MsgID, e.Shape)
+}
+
+func (e *MsgFormatError) Unwrap() error {
+	return ErrInvalidMsgFormat
+}
+
+// describeShape 描述 redis 回复的结构, 嵌套数组只展开两层
+func describeShape(v interface{}) string {
+	return describeShapeDepth(v, 2)
+}
+
+func describeShapeDepth(v interface{}, depth int) string {
+	switch val := v.(type) {
+	case nil:
+		return "nil"
+	case []byte, string:
+		return "bulk"
+	case int64:
+		return "int"
+	case []interface{}:
+		if depth <= 0 {
+			return fmt.Sprintf("array(%d)", len(val))
+		}
+		elems := make([]string, 0, len(val))
+		for _, elem := range val {
+			elems = append(elems, describeShapeDepth(elem, depth-1))
+		}
+		return fmt.Sprintf("array(%d)[%s]", len(val), strings.Join(elems, ", "))
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// parseMsgEntities 将 stream 返回的 [[id, [key, val]], ...] 格式数据转换为消息实体.
+// onInvalid 不为 nil 时跳过格式错误的消息并回调, 否则遇到格式错误的消息直接返回错误
+func parseMsgEntities(topic string, rawMsgs []interface{}, names FieldNames, onInvalid func(err *MsgFormatError)) ([]*MsgEntity, error) {
+	var msg []*MsgEntity
+	for i, rawMsg := range rawMsgs {
+		entity, err := parseMsgEntity(rawMsg, names)
+		if err != nil {
+			err.Topic = topic
+			err.Index = i
+			if onInvalid == nil {
+				return nil, err
+			}
+			onInvalid(err)
+			continue
+		}
+		msg = append(msg, entity)
+	}
+
+	return msg, nil
+}
+
+// parseMsgEntity 解析单条 [id, [key, val, ...]] 格式的消息. 字段列表中第一对字段作为 key/val, 其余作为附加字段;
+// 设置了固定字段名时按字段名取出 key/val, 不包含固定字段的旧格式消息仍按第一对字段解析.
+// 字段列表为空(例如 pending 消息已被删除)时 key/val 为空, 字段数为奇数时最后一个字段的值视为空字符串.
+// 只有消息本身不是 [id, 字段列表] 结构时才返回错误
+func parseMsgEntity(rawMsg interface{}, names FieldNames) (*MsgEntity, *MsgFormatError) {
+	_msg, _ := rawMsg.([]interface{})
+	if len(_msg) != 2 {
+		return nil, &MsgFormatError{Shape: describeShape(rawMsg)}
+	}
+	msgID := gocast.ToString(_msg[0])
+	if msgID == "" {
+		return nil, &MsgFormatError{Shape: describeShape(rawMsg)}
+	}
+	msgBody, ok := _msg[1].([]interface{})
+	if !ok && _msg[1] != nil {
+		return nil, &MsgFormatError{MsgID: msgID, Shape: describeShape(rawMsg)}
+	}
+
+	fields := make([]string, 0, len(msgBody)+1)
+	for _, field := range msgBody {
+		fields = append(fields, gocast.ToString(field))
+	}
+	if len(fields)%2 != 0 {
+		fields = append(fields, "")
+	}
+
+	entity := &MsgEntity{MsgID: msgID}
+	if names.fixed() && parseFixedFields(entity, fields, names) {
+		return entity, nil
+	}
+
+	if len(fields) >= 2 {
+		entity.Key = fields[0]
+		entity.Val = fields[1]
+	}
+
+	// key/val 之后的字段作为附加字段
+	if len(fields) > 2 {
+		entity.Headers = make(map[string]string, (len(fields)-2)/2)
+		for i := 2; i < len(fields); i += 2 {
+			entity.Headers[fields[i]] = fields[i+1]
+		}
+	}
+
+	return entity, nil
+}
+
+// parseFixedFields 按固定字段名取出 key/val, 其余字段作为附加字段. 两个固定字段都不存在时返回 false
+func parseFixedFields(entity *MsgEntity, fields []string, names FieldNames) bool {
+	found := false
+	for i := 0; i < len(fields); i += 2 {
+		switch fields[i] {
+		case names.Key:
+			entity.Key = fields[i+1]
+			found = true
+		case names.Val:
+			entity.Val = fields[i+1]
+			found = true
+		default:
+			if entity.Headers == nil {
+				entity.Headers = make(map[string]string)
+			}
+			entity.Headers[fields[i]] = fields[i+1]
+		}
+	}
+
+	if !found {
+		entity.Key, entity.Val, entity.Headers = "", "", nil
+	}
+	return found
+}
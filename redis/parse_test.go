@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMsgEntity(t *testing.T) {
+	fixed := FieldNames{Key: "k", Val: "v"}
+
+	tests := []struct {
+		name    string
+		raw     interface{}
+		names   FieldNames
+		want    *MsgEntity
+		wantErr bool
+	}{
+		{
+			name: "key val",
+			raw:  []interface{}{[]byte("1-0"), []interface{}{[]byte("key"), []byte("val")}},
+			want: &MsgEntity{MsgID: "1-0", Key: "key", Val: "val"},
+		},
+		{
+			name: "headers",
+			raw:  []interface{}{[]byte("1-0"), []interface{}{[]byte("key"), []byte("val"), []byte("h"), []byte("1")}},
+			want: &MsgEntity{MsgID: "1-0", Key: "key", Val: "val", Headers: map[string]string{"h": "1"}},
+		},
+		{
+			name: "odd fields",
+			raw:  []interface{}{[]byte("1-0"), []interface{}{[]byte("key"), []byte("val"), []byte("h")}},
+			want: &MsgEntity{MsgID: "1-0", Key: "key", Val: "val", Headers: map[string]string{"h": ""}},
+		},
+		{
+			name: "deleted pending msg",
+			raw:  []interface{}{[]byte("1-0"), nil},
+			want: &MsgEntity{MsgID: "1-0"},
+		},
+		{
+			name:  "fixed field names",
+			raw:   []interface{}{[]byte("1-0"), []interface{}{[]byte("h"), []byte("1"), []byte("v"), []byte("val"), []byte("k"), []byte("key")}},
+			names: fixed,
+			want:  &MsgEntity{MsgID: "1-0", Key: "key", Val: "val", Headers: map[string]string{"h": "1"}},
+		},
+		{
+			name:  "fixed field names fall back to first pair",
+			raw:   []interface{}{[]byte("1-0"), []interface{}{[]byte("key"), []byte("val")}},
+			names: fixed,
+			want:  &MsgEntity{MsgID: "1-0", Key: "key", Val: "val"},
+		},
+		{name: "not array", raw: []byte("1-0"), wantErr: true},
+		{name: "wrong length", raw: []interface{}{[]byte("1-0")}, wantErr: true},
+		{name: "empty id", raw: []interface{}{[]byte(""), []interface{}{}}, wantErr: true},
+		{name: "body not array", raw: []interface{}{[]byte("1-0"), []byte("body")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMsgEntity(tt.raw, tt.names)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parse succeeded: %+v, want error", got)
+				}
+				if !errors.Is(err, ErrInvalidMsgFormat) {
+					t.Fatalf("err = %v, want ErrInvalidMsgFormat", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("entity = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMsgEntitiesInvalid(t *testing.T) {
+	raw := []interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("a"), []byte("1")}},
+		[]byte("garbage"),
+		[]interface{}{[]byte("2-0"), []interface{}{[]byte("b"), []byte("2")}},
+	}
+
+	_, err := parseMsgEntities("topic", raw, FieldNames{}, nil)
+	var formatErr *MsgFormatError
+	if !errors.As(err, &formatErr) {
+		t.Fatalf("err = %v, want MsgFormatError", err)
+	}
+	if formatErr.Topic != "topic" || formatErr.Index != 1 || formatErr.Shape != "bulk" {
+		t.Fatalf("format err = %+v", formatErr)
+	}
+
+	// 设置了 onInvalid 时跳过格式错误的消息
+	var skipped []*MsgFormatError
+	msgs, err := parseMsgEntities("topic", raw, FieldNames{}, func(err *MsgFormatError) {
+		skipped = append(skipped, err)
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].MsgID != "1-0" || msgs[1].MsgID != "2-0" {
+		t.Fatalf("msgs = %+v, want 1-0 and 2-0", msgs)
+	}
+	if len(skipped) != 1 || skipped[0].Index != 1 {
+		t.Fatalf("skipped = %+v, want index 1", skipped)
+	}
+}
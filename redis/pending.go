@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/demdxx/gocast"
+	"github.com/gomodule/redigo/redis"
+)
+
+// PendingEntry XPENDING 返回的单条 pending 消息信息
+type PendingEntry struct {
+	// MsgID 消息 ID
+	MsgID string
+	// Consumer 当前持有该消息的消费者
+	Consumer string
+	// IdleTime 消息自上次投递以来的空闲时长
+	IdleTime time.Duration
+	// DeliveryCount 消息被投递的次数
+	DeliveryCount int64
+}
+
+// XPendingExt 查询消费者组中 [start, end] 范围内的 pending 消息详情, consumer 为空时查询整个消费者组
+func (c *Client) XPendingExt(ctx context.Context, topic, groupID, start, end string, count int, consumer string) ([]*PendingEntry, error) {
+	return c.xPending(ctx, topic, groupID, 0, start, end, count, consumer)
+}
+
+// XPendingIdle 查询消费者组中空闲时长不小于 minIdle 的 pending 消息详情(redis 6.2+), consumer 为空时查询整个消费者组
+func (c *Client) XPendingIdle(ctx context.Context, topic, groupID string, minIdle time.Duration, count int, consumer string) ([]*PendingEntry, error) {
+	return c.xPending(ctx, topic, groupID, minIdle, "-", "+", count, consumer)
+}
+
+func (c *Client) xPending(ctx context.Context, topic, groupID string, minIdle time.Duration, start, end string, count int, consumer string) ([]*PendingEntry, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis XPENDING: %w", ErrEmptyTopic)
+	}
+	if groupID == "" || start == "" || end == "" {
+		return nil, errors.New("redis XPENDING group_id | start | end can't be empty")
+	}
+
+	args := []interface{}{topic, groupID}
+	if minIdle > 0 {
+		args = append(args, "IDLE", minIdle.Milliseconds())
+	}
+	args = append(args, start, end, count)
+	if consumer != "" {
+		args = append(args, consumer)
+	}
+
+	reply, err := redis.Values(c.do(ctx, "XPENDING", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*PendingEntry, 0, len(reply))
+	for _, rawEntry := range reply {
+		entry, _ := rawEntry.([]interface{})
+		if len(entry) != 4 {
+			return nil, errors.New("invalid xpending reply format")
+		}
+		entries = append(entries, &PendingEntry{
+			MsgID:         gocast.ToString(entry[0]),
+			Consumer:      gocast.ToString(entry[1]),
+			IdleTime:      time.Duration(gocast.ToInt64(entry[2])) * time.Millisecond,
+			DeliveryCount: gocast.ToInt64(entry[3]),
+		})
+	}
+
+	return entries, nil
+}
+
+// XClaim 将空闲时长不小于 minIdle 的 pending 消息转移给 consumer, 返回认领成功的消息.
+// 认领期间已被其他消费者处理或已被删除的消息不会返回
+func (c *Client) XClaim(ctx context.Context, topic, groupID, consumer string, minIdle time.Duration, msgIDs ...string) ([]*MsgEntity, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("redis XCLAIM: %w", ErrEmptyTopic)
+	}
+	if groupID == "" || consumer == "" || len(msgIDs) == 0 {
+		return nil, errors.New("redis XCLAIM group_id | consumer | msg_ids can't be empty")
+	}
+
+	args := make([]interface{}, 0, 4+len(msgIDs))
+	args = append(args, topic, groupID, consumer, minIdle.Milliseconds())
+	for _, msgID := range msgIDs {
+		args = append(args, msgID)
+	}
+
+	reply, err := redis.Values(c.do(ctx, "XCLAIM", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	// 低版本 redis 中已被删除的消息会以 nil 返回
+	rawMsgs := reply[:0]
+	for _, rawMsg := range reply {
+		if rawMsg != nil {
+			rawMsgs = append(rawMsgs, rawMsg)
+		}
+	}
+
+	return parseMsgEntities(topic, rawMsgs, c.options.fieldNames, nil)
+}
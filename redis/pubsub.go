@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PubSubMsg 通过 pub/sub 接收到的消息
+type PubSubMsg struct {
+	Channel string
+	Data    string
+}
+
+// Subscription pub/sub 订阅, 消息不会持久化, 适用于无需可靠投递的通知场景
+type Subscription struct {
+	psc   redis.PubSubConn
+	msgCh chan *PubSubMsg
+	err   error
+
+	// closeCh 在 Close 时关闭, doneCh 在接收协程退出时关闭
+	once    sync.Once
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// Publish 向 channel 发布消息, 返回接收到消息的订阅者数量
+func (c *Client) Publish(ctx context.Context, channel, msg string) (int64, error) {
+	if channel == "" {
+		return -1, errors.New("redis PUBLISH channel can't be empty")
+	}
+
+	return redis.Int64(c.do(ctx, "PUBLISH", channel, msg))
+}
+
+// Subscribe 订阅 channel, 通过 Subscription.Messages 读取消息. ctx 结束或调用 Close 后取消订阅并关闭消息 channel
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("redis SUBSCRIBE channels can't be empty")
+	}
+
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(channels))
+	for _, channel := range channels {
+		args = append(args, channel)
+	}
+
+	s := &Subscription{
+		psc:     redis.PubSubConn{Conn: conn},
+		msgCh:   make(chan *PubSubMsg),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	if err := s.psc.Subscribe(args...); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	go s.receive()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.doneCh:
+		}
+	}()
+
+	return s, nil
+}
+
+// Messages 返回接收消息的 channel, 订阅结束后 channel 会被关闭
+func (s *Subscription) Messages() <-chan *PubSubMsg {
+	return s.msgCh
+}
+
+// Err 返回导致订阅异常结束的错误, 需要在消息 channel 关闭后调用
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close 取消订阅, 可以重复调用
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.closeCh)
+		_ = s.psc.Unsubscribe()
+	})
+}
+
+func (s *Subscription) receive() {
+	defer func() {
+		_ = s.psc.Close()
+		close(s.msgCh)
+		close(s.doneCh)
+	}()
+
+	for {
+		// 订阅期间不使用读超时, 由取消订阅结束阻塞
+		switch reply := s.psc.ReceiveWithTimeout(0).(type) {
+		case redis.Message:
+			select {
+			case s.msgCh <- &PubSubMsg{Channel: reply.Channel, Data: string(reply.Data)}:
+			case <-s.closeCh:
+				// 已取消订阅, 丢弃剩余消息直到收到取消订阅的确认
+			}
+		case redis.Subscription:
+			// 所有 channel 都已取消订阅
+			if reply.Count == 0 {
+				return
+			}
+		case error:
+			s.err = reply
+			return
+		}
+	}
+}
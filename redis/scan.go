@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/demdxx/gocast"
+	"github.com/gomodule/redigo/redis"
+)
+
+// ScanIterator 基于 SCAN 游标分页遍历 key 的迭代器, 不会像 KEYS 一样阻塞 redis
+//
+//	iter, _ := client.Scan(ctx, "lock:*", 100)
+//	for iter.Next() {
+//		fmt.Println(iter.Val())
+//	}
+//	if err := iter.Err(); err != nil { ... }
+type ScanIterator struct {
+	ctx    context.Context
+	client *Client
+	match  string
+	count  int
+
+	cursor string
+	keys   []string
+	val    string
+	done   bool
+	err    error
+}
+
+// Scan 按照 match 模式遍历 key, count 为每次 SCAN 的建议返回条数, match 为空时遍历所有 key
+func (c *Client) Scan(ctx context.Context, match string, count int) (*ScanIterator, error) {
+	if count < 0 {
+		return nil, errors.New("redis SCAN count can't be negative")
+	}
+
+	return &ScanIterator{
+		ctx:    ctx,
+		client: c,
+		match:  match,
+		count:  count,
+		cursor: "0",
+	}, nil
+}
+
+// Next 移动到下一个 key, 遍历结束或出错时返回 false
+func (it *ScanIterator) Next() bool {
+	for len(it.keys) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.err = it.fetch()
+	}
+
+	it.val = it.keys[0]
+	it.keys = it.keys[1:]
+	return true
+}
+
+// Val 返回当前的 key
+func (it *ScanIterator) Val() string {
+	return it.val
+}
+
+// Err 返回遍历过程中出现的错误
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+// fetch 执行一次 SCAN 获取下一页 key
+func (it *ScanIterator) fetch() error {
+	args := []interface{}{it.cursor}
+	if it.match != "" {
+		args = append(args, "MATCH", it.match)
+	}
+	if it.count > 0 {
+		args = append(args, "COUNT", it.count)
+	}
+
+	reply, err := redis.Values(it.client.do(it.ctx, "SCAN", args...))
+	if err != nil {
+		return err
+	}
+	if len(reply) != 2 {
+		return errors.New("invalid scan reply format")
+	}
+
+	keys, err := redis.Strings(reply[1], nil)
+	if err != nil {
+		return err
+	}
+
+	it.cursor = gocast.ToString(reply[0])
+	it.keys = keys
+	// 游标回到 0 表示遍历结束
+	it.done = it.cursor == "0"
+	return nil
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Script lua 脚本, 创建时计算好 sha1 摘要, 执行时优先使用 EVALSHA 以节省带宽
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript 新建 lua 脚本
+func NewScript(src string) *Script {
+	h := sha1.New()
+	_, _ = h.Write([]byte(src))
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(h.Sum(nil)),
+	}
+}
+
+// Hash 返回脚本的 sha1 摘要
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Src 返回脚本源码
+func (s *Script) Src() string {
+	return s.src
+}
+
+// args 组装 EVAL / EVALSHA 的参数
+func (s *Script) args(spec string, keys []string, args []interface{}) []interface{} {
+	evalArgs := make([]interface{}, 0, 2+len(keys)+len(args))
+	evalArgs = append(evalArgs, spec, len(keys))
+	for _, key := range keys {
+		evalArgs = append(evalArgs, key)
+	}
+	return append(evalArgs, args...)
+}
+
+// EvalScript 执行 lua 脚本, 先尝试 EVALSHA, 若 redis 中未缓存该脚本(NOSCRIPT)则退化为 EVAL,
+// EVAL 执行后脚本会被 redis 缓存, 后续调用即可直接命中 EVALSHA
+func (c *Client) EvalScript(ctx context.Context, s *Script, keys []string, args []interface{}) (interface{}, error) {
+	if s == nil {
+		return nil, errors.New("redis EVALSHA script can't be empty")
+	}
+
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	reply, err := redis.DoContext(conn, ctx, "EVALSHA", s.args(s.hash, keys, args)...)
+	if isNoScriptErr(err) {
+		reply, err = redis.DoContext(conn, ctx, "EVAL", s.args(s.src, keys, args)...)
+	}
+	if err != nil {
+		return nil, wrapScriptErr(s.src, len(keys), len(args), err)
+	}
+
+	return reply, nil
+}
+
+// ScriptLoad 预先将脚本加载到 redis 的脚本缓存中
+func (c *Client) ScriptLoad(ctx context.Context, s *Script) error {
+	if s == nil {
+		return errors.New("redis SCRIPT LOAD script can't be empty")
+	}
+
+	hash, err := redis.String(c.do(ctx, "SCRIPT", "LOAD", s.src))
+	if err != nil {
+		return err
+	}
+	if hash != s.hash {
+		return errors.New("redis SCRIPT LOAD returned unexpected sha1")
+	}
+
+	return nil
+}
+
+// isNoScriptErr 判断是否为脚本未缓存的错误
+func isNoScriptErr(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT")
+}
+
+// scriptPreviewLen 错误信息中脚本预览的最大长度
+const scriptPreviewLen = 80
+
+// wrapScriptErr 为脚本执行失败的 error 附加脚本预览与参数个数, 便于定位是哪个脚本出错
+func wrapScriptErr(src string, keyCount, argCount int, err error) error {
+	return fmt.Errorf("redis EVAL script %q, keys: %d, args: %d: %w", scriptPreview(src), keyCount, argCount, err)
+}
+
+// scriptPreview 将脚本压缩为单行, 超出 scriptPreviewLen 个字符的部分截断, 按字符截断以免破坏多字节字符
+func scriptPreview(src string) string {
+	preview := []rune(strings.Join(strings.Fields(src), " "))
+	if len(preview) > scriptPreviewLen {
+		return string(preview[:scriptPreviewLen]) + "..."
+	}
+	return string(preview)
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamID 解析后的 stream 消息 ID, 格式为 <毫秒时间戳>-<序号>
+type StreamID struct {
+	Ms  int64
+	Seq int64
+}
+
+// ParseStreamID 解析 stream 消息 ID, 得到毫秒时间戳与序号. 省略序号时(例如 1690000000000)序号为 0
+func ParseStreamID(id string) (ms int64, seq int64, err error) {
+	msPart, seqPart, hasSeq := strings.Cut(id, "-")
+	ms, err = strconv.ParseInt(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream id: %s", id)
+	}
+
+	if hasSeq {
+		seq, err = strconv.ParseInt(seqPart, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid stream id: %s", id)
+		}
+	}
+
+	return ms, seq, nil
+}
+
+// NewStreamID 将 stream 消息 ID 字符串解析为 StreamID
+func NewStreamID(id string) (StreamID, error) {
+	ms, seq, err := ParseStreamID(id)
+	if err != nil {
+		return StreamID{}, err
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// String 返回 <毫秒时间戳>-<序号> 格式的消息 ID
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Time 返回消息 ID 中的时间戳, 即消息写入 stream 的时间
+func (id StreamID) Time() time.Time {
+	return time.UnixMilli(id.Ms)
+}
+
+// Less 判断 id 是否排在 other 之前
+func (id StreamID) Less(other StreamID) bool {
+	if id.Ms != other.Ms {
+		return id.Ms < other.Ms
+	}
+	return id.Seq < other.Seq
+}
@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStreamID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantMs  int64
+		wantSeq int64
+		wantErr bool
+	}{
+		{id: "1690000000000-5", wantMs: 1690000000000, wantSeq: 5},
+		{id: "1690000000000", wantMs: 1690000000000},
+		{id: "0-0"},
+		{id: "", wantErr: true},
+		{id: "abc-1", wantErr: true},
+		{id: "1-abc", wantErr: true},
+		{id: "1-", wantErr: true},
+		{id: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			ms, seq, err := ParseStreamID(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want err %v", err, tt.wantErr)
+			}
+			if ms != tt.wantMs || seq != tt.wantSeq {
+				t.Fatalf("ParseStreamID(%q) = %d, %d, want %d, %d", tt.id, ms, seq, tt.wantMs, tt.wantSeq)
+			}
+		})
+	}
+}
+
+func TestStreamID(t *testing.T) {
+	id, err := NewStreamID("1690000000123-7")
+	if err != nil {
+		t.Fatalf("new stream id: %v", err)
+	}
+	if got := id.String(); got != "1690000000123-7" {
+		t.Fatalf("String() = %s", got)
+	}
+	if got := id.Time(); !got.Equal(time.UnixMilli(1690000000123)) {
+		t.Fatalf("Time() = %s", got)
+	}
+
+	tests := []struct {
+		a, b StreamID
+		want bool
+	}{
+		{a: StreamID{Ms: 1, Seq: 0}, b: StreamID{Ms: 2, Seq: 0}, want: true},
+		{a: StreamID{Ms: 1, Seq: 1}, b: StreamID{Ms: 1, Seq: 2}, want: true},
+		{a: StreamID{Ms: 1, Seq: 2}, b: StreamID{Ms: 1, Seq: 2}, want: false},
+		{a: StreamID{Ms: 2, Seq: 0}, b: StreamID{Ms: 1, Seq: 9}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Less(tt.b); got != tt.want {
+			t.Fatalf("%s.Less(%s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// ErrInvalidTopic topic 包含空白, 控制字符, 或者不符合 WithTopicValidation 设置的规则, 属于使用错误, 重试无意义
+var ErrInvalidTopic = errors.New("invalid topic")
+
+// checkTopic 在执行写入与创建消费者组的命令前校验 topic, 尽早发现配置错误
+func (c *Client) checkTopic(cmd, topic string) error {
+	if topic == "" {
+		return fmt.Errorf("redis %s: %w", cmd, ErrEmptyTopic)
+	}
+
+	for _, r := range topic {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("redis %s: %w: %q contains whitespace or control characters", cmd, ErrInvalidTopic, topic)
+		}
+	}
+	if c.options.maxTopicLen > 0 && len(topic) > c.options.maxTopicLen {
+		return fmt.Errorf("redis %s: %w: %q exceeds %d bytes", cmd, ErrInvalidTopic, topic, c.options.maxTopicLen)
+	}
+	if c.options.topicPattern != nil && !c.options.topicPattern.MatchString(topic) {
+		return fmt.Errorf("redis %s: %w: %q doesn't match %s", cmd, ErrInvalidTopic, topic, c.options.topicPattern)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"errors"
+	"time"
+)
+
+// TrimStrategy stream 裁剪策略, MaxLen 与 MinID 二选一
+type TrimStrategy struct {
+	// MaxLen 按长度裁剪, 最多保留 MaxLen 条消息
+	MaxLen int64
+	// MinID 按消息 ID 裁剪, ID 小于 MinID 的消息会被删除
+	MinID string
+	// Approx 为 true 时使用近似裁剪(~), redis 只会删除整个宏节点, 性能更好但保留的消息可能略多
+	Approx bool
+}
+
+// TrimByMaxLen 按长度裁剪
+func TrimByMaxLen(maxLen int64, approx bool) TrimStrategy {
+	return TrimStrategy{MaxLen: maxLen, Approx: approx}
+}
+
+// TrimByMinID 按消息 ID 裁剪
+func TrimByMinID(minID string, approx bool) TrimStrategy {
+	return TrimStrategy{MinID: minID, Approx: approx}
+}
+
+// TrimBefore 按时间裁剪, 删除早于 t 写入的消息
+func TrimBefore(t time.Time, approx bool) TrimStrategy {
+	return TrimByMinID(MinIDFromTime(t), approx)
+}
+
+// MinIDFromTime 将时间转换为 stream 消息 ID, 可作为 MINID 的参数
+func MinIDFromTime(t time.Time) string {
+	return StreamID{Ms: t.UnixMilli()}.String()
+}
+
+// args 生成 XTRIM / XADD 中裁剪部分的参数
+func (s TrimStrategy) args() ([]interface{}, error) {
+	if s.MinID != "" && s.MaxLen > 0 {
+		return nil, errors.New("redis trim strategy MAXLEN and MINID can't be both set")
+	}
+
+	var args []interface{}
+	switch {
+	case s.MinID != "":
+		args = append(args, "MINID")
+	case s.MaxLen >= 0:
+		args = append(args, "MAXLEN")
+	default:
+		return nil, errors.New("redis trim strategy MAXLEN can't be negative")
+	}
+
+	if s.Approx {
+		args = append(args, "~")
+	}
+
+	if s.MinID != "" {
+		args = append(args, s.MinID)
+	} else {
+		args = append(args, s.MaxLen)
+	}
+
+	return args, nil
+}
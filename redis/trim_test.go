@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTrimStrategyArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy TrimStrategy
+		want     []interface{}
+		wantErr  bool
+	}{
+		{name: "max len", strategy: TrimByMaxLen(100, false), want: []interface{}{"MAXLEN", int64(100)}},
+		{name: "approx max len", strategy: TrimByMaxLen(100, true), want: []interface{}{"MAXLEN", "~", int64(100)}},
+		{name: "min id", strategy: TrimByMinID("5-0", false), want: []interface{}{"MINID", "5-0"}},
+		{name: "approx min id", strategy: TrimByMinID("5-0", true), want: []interface{}{"MINID", "~", "5-0"}},
+		{name: "both set", strategy: TrimStrategy{MaxLen: 1, MinID: "5-0"}, wantErr: true},
+		{name: "negative max len", strategy: TrimStrategy{MaxLen: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.strategy.args()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want err %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("args = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXTrimRejectsEmptyStrategy(t *testing.T) {
+	// 参数校验在发送命令之前, 无需 redis
+	client := NewClient("tcp", "127.0.0.1:0", "")
+	defer client.Close()
+
+	for _, strategy := range []TrimStrategy{{}, {Approx: true}, {MaxLen: -1}} {
+		if _, err := client.XTrim(context.Background(), "topic", strategy); err == nil {
+			t.Fatalf("XTrim(%+v) succeeded, want error", strategy)
+		}
+	}
+}
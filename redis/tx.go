@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrTxAborted 事务执行期间 WATCH 的 key 被修改, EXEC 未执行任何命令
+var ErrTxAborted = errors.New("transaction aborted by watched key change")
+
+// Tx 基于 MULTI/EXEC 的事务, 只能在 Client.Tx 的回调函数中使用
+type Tx struct {
+	ctx   context.Context
+	conn  redis.Conn
+	multi bool
+}
+
+// Watch 监视 key, 若 EXEC 前 key 被其他客户端修改则事务不会执行. 需要在 Send 之前调用
+func (tx *Tx) Watch(keys ...string) error {
+	if tx.multi {
+		return errors.New("redis WATCH can't be called after MULTI")
+	}
+	if len(keys) == 0 {
+		return errors.New("redis WATCH keys can't be empty")
+	}
+
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	_, err := redis.DoContext(tx.conn, tx.ctx, "WATCH", args...)
+	return err
+}
+
+// Do 在 MULTI 之前立即执行命令, 一般用于 WATCH 之后读取当前值. 需要在 Send 之前调用
+func (tx *Tx) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if tx.multi {
+		return nil, errors.New("redis Tx.Do can't be called after MULTI, use Send instead")
+	}
+
+	return redis.DoContext(tx.conn, tx.ctx, commandName, args...)
+}
+
+// Send 将命令加入事务队列, 命令在 EXEC 时统一执行
+func (tx *Tx) Send(commandName string, args ...interface{}) error {
+	if !tx.multi {
+		if err := tx.conn.Send("MULTI"); err != nil {
+			return err
+		}
+		tx.multi = true
+	}
+
+	return tx.conn.Send(commandName, args...)
+}
+
+// Tx 执行事务, fn 中通过 tx.Send 将命令加入队列, fn 返回 nil 后执行 EXEC 并按顺序返回各命令的结果,
+// fn 返回错误时放弃事务. 配合 tx.Watch 可实现乐观锁, WATCH 的 key 被修改时返回 ErrTxAborted
+func (c *Client) Tx(ctx context.Context, fn func(tx *Tx) error) ([]interface{}, error) {
+	if fn == nil {
+		return nil, errors.New("redis Tx fn can't be empty")
+	}
+
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	tx := &Tx{ctx: ctx, conn: conn}
+	if err := fn(tx); err != nil {
+		if tx.multi {
+			_, _ = conn.Do("DISCARD")
+		} else {
+			_, _ = conn.Do("UNWATCH")
+		}
+		return nil, err
+	}
+
+	if !tx.multi {
+		if err := conn.Send("MULTI"); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := redis.DoContext(conn, ctx, "EXEC")
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrTxAborted
+	}
+
+	return redis.Values(reply, nil)
+}
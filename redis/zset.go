@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ZAdd 向有序集合中添加成员, 返回新增的成员个数
+func (c *Client) ZAdd(ctx context.Context, key string, score int64, member string) (int64, error) {
+	if key == "" {
+		return -1, fmt.Errorf("redis ZADD: %w", ErrEmptyKey)
+	}
+
+	return redis.Int64(c.do(ctx, "ZADD", key, score, member))
+}
+
+// ZAddWithList 在同一个事务中将 items 写入列表 listKey 并向有序集合 key 添加成员,
+// 列表元素按原样存储, 可用于保存二进制内容. listKey 中已有的内容会被覆盖
+func (c *Client) ZAddWithList(ctx context.Context, key string, score int64, member, listKey string, items []string) error {
+	if key == "" || listKey == "" {
+		return fmt.Errorf("redis ZADD: %w", ErrEmptyKey)
+	}
+	if len(items) == 0 {
+		return errors.New("redis RPUSH items can't be empty")
+	}
+
+	args := make([]interface{}, 0, len(items)+1)
+	args = append(args, listKey)
+	for _, item := range items {
+		args = append(args, item)
+	}
+
+	_, err := c.Tx(ctx, func(tx *Tx) error {
+		if err := tx.Send("DEL", listKey); err != nil {
+			return err
+		}
+		if err := tx.Send("RPUSH", args...); err != nil {
+			return err
+		}
+		return tx.Send("ZADD", key, score, member)
+	})
+	return err
+}
+
+// ZRangeByScore 按分数从小到大返回有序集合中分数位于 [min, max] 的成员, 最多 count 个, min/max 支持 "-inf"/"+inf"
+func (c *Client) ZRangeByScore(ctx context.Context, key, min, max string, count int) ([]string, error) {
+	if key == "" {
+		return nil, fmt.Errorf("redis ZRANGEBYSCORE: %w", ErrEmptyKey)
+	}
+
+	return redis.Strings(c.do(ctx, "ZRANGEBYSCORE", key, min, max, "LIMIT", 0, count))
+}
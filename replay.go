@@ -0,0 +1,51 @@
+package redis_mq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// replayBatchSize 重放时单次 XRANGE 读取的消息条数
+const replayBatchSize = 100
+
+// Replay 通过 XRANGE 读取 topic 中 [fromID, toID] 范围内的历史消息, 并依次交给回调函数处理, 可用于回填和重跑.
+// 重放不经过消费者组, 不会 ack, 也不会影响消费者组的读取位置. fromID 与 toID 可以使用 - 和 +.
+// 回调函数返回 error 时停止重放, 返回的 error 中包含失败消息的 id, 可以从该位置继续重放
+func (c *Consumer) Replay(ctx context.Context, fromID, toID string) error {
+	start := fromID
+	for {
+		msgs, err := c.client.XRange(ctx, c.topic, start, toID, replayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			if err := c.replayMsg(ctx, msg); err != nil {
+				return fmt.Errorf("replay msg %s failed: %w", msg.MsgID, err)
+			}
+		}
+
+		if len(msgs) < replayBatchSize {
+			return nil
+		}
+
+		// 从最后一条消息之后继续读取
+		lastID, err := redis.NewStreamID(msgs[len(msgs)-1].MsgID)
+		if err != nil {
+			return err
+		}
+		start = redis.StreamID{Ms: lastID.Ms, Seq: lastID.Seq + 1}.String()
+	}
+}
+
+// replayMsg 在处理消息超时时长内执行回调函数
+func (c *Consumer) replayMsg(ctx context.Context, msg *redis.MsgEntity) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.handleMsgTimeout)
+	defer cancel()
+
+	err := c.invokeCallback(ctx, msg)
+	c.opts.onResult(ctx, msg, err, 1)
+	return err
+}
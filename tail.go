@@ -0,0 +1,147 @@
+package redis_mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// tailErrBackoff 读取失败后重试前的等待时长
+const tailErrBackoff = time.Second
+
+// TailConsumer 不使用消费者组的广播消费者, 通过 XREAD 按顺序读取 topic 中的消息, 每个 TailConsumer 都能读到全部消息.
+// 消息不需要 ack, 回调函数执行失败时只记录日志, 不会重试; 读取位置只保存在内存中, 重启后从 startID 重新开始
+type TailConsumer struct {
+	ctx      context.Context
+	stop     context.CancelFunc
+	stopOnce sync.Once
+
+	client       TailClient
+	topic        string
+	lastID       string
+	callbackFunc MsgCallback
+
+	opts *TailConsumerOptions
+}
+
+// NewTailConsumer 新建并启动广播消费者, 从 ID 大于 startID 的消息开始读取.
+// startID 为空或 "$" 时只读取启动之后写入的消息, 为 "0-0" 时从头读取
+func NewTailConsumer(client TailClient, topic, startID string, callbackFunc MsgCallback, opts ...TailConsumerOption) (*TailConsumer, error) {
+	if client == nil {
+		return nil, errors.New("redis client can't be empty")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("redis tail consumer: %w", redis.ErrEmptyTopic)
+	}
+	if callbackFunc == nil {
+		return nil, errors.New("callback function can't be empty")
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	t := TailConsumer{
+		ctx:          ctx,
+		stop:         stop,
+		client:       client,
+		topic:        topic,
+		callbackFunc: callbackFunc,
+		opts:         &TailConsumerOptions{},
+	}
+
+	for _, opt := range opts {
+		opt(t.opts)
+	}
+
+	repairTailConsumer(t.opts)
+
+	// 每轮都使用 "$" 会漏掉两次读取之间写入的消息, 因此启动时先换算为当前最新的消息 ID
+	lastID, err := t.resolveStartID(startID)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	t.lastID = lastID
+
+	go t.run()
+	return &t, nil
+}
+
+// Stop 停止广播消费者, 可以重复或并发调用
+func (t *TailConsumer) Stop() {
+	t.stopOnce.Do(t.stop)
+}
+
+func (t *TailConsumer) resolveStartID(startID string) (string, error) {
+	if startID != "" && startID != "$" {
+		return startID, nil
+	}
+
+	msgs, err := t.client.XTail(t.ctx, t.topic, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0-0", nil
+	}
+	return msgs[0].MsgID, nil
+}
+
+func (t *TailConsumer) run() {
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		default:
+		}
+
+		msgs, err := t.client.XRead(t.ctx, t.topic, t.lastID, t.opts.batchSize, t.blockMilliseconds())
+		if errors.Is(err, redis.ErrNoMsg) {
+			continue
+		}
+		if err != nil {
+			if t.ctx.Err() != nil {
+				return
+			}
+			t.opts.logger.ErrorFormat("tail msg failed, topic: %s, err: %v", t.topic, err)
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(tailErrBackoff):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			t.handleMsg(msg)
+			t.lastID = msg.MsgID
+		}
+	}
+}
+
+// blockMilliseconds 返回 XREAD BLOCK 的毫秒数, 不足 1ms 时按 1ms 处理, 避免变为无限阻塞
+func (t *TailConsumer) blockMilliseconds() int {
+	if ms := int(t.opts.blockTimeout.Milliseconds()); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+// handleMsg 执行回调函数, 失败或 panic 时只记录日志
+func (t *TailConsumer) handleMsg(msg *redis.MsgEntity) {
+	ctx, cancel := context.WithTimeout(t.ctx, t.opts.handleMsgTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.opts.logger.ErrorFormat("tail msg callback panic, msg id: %s, panic: %v, stack: %s", msg.MsgID, r, debug.Stack())
+		}
+	}()
+
+	if err := t.callbackFunc(ctx, msg); err != nil {
+		t.opts.logger.ErrorFormat("tail msg callback failed, topic: %s, msg id: %s, err: %v", t.topic, msg.MsgID, err)
+	}
+}
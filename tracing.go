@@ -0,0 +1,58 @@
+package redis_mq
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// tracePropagator 使用 W3C trace context 格式(traceparent / tracestate)在消息中传递 trace 上下文
+var tracePropagator = propagation.TraceContext{}
+
+// injectTraceHeaders 将 ctx 中的 trace 上下文转换为消息附加字段, ctx 中没有有效 span 时返回 nil
+func injectTraceHeaders(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+
+	carrier := propagation.MapCarrier{}
+	tracePropagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// extractTraceContext 从消息附加字段中提取 trace 上下文
+func extractTraceContext(ctx context.Context, msg *redis.MsgEntity) context.Context {
+	if len(msg.Headers) == 0 {
+		return ctx
+	}
+	return tracePropagator.Extract(ctx, propagation.MapCarrier(msg.Headers))
+}
+
+// startSpan 发送消息时创建 producer span
+func (p *Producer) startSpan(ctx context.Context, topic string) (context.Context, trace.Span) {
+	return p.opts.tracer.Start(ctx, topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+}
+
+// startSpan 处理消息时创建 consumer span, 其父 span 为生产者写入消息中的 trace 上下文
+func (c *Consumer) startSpan(ctx context.Context, msg *redis.MsgEntity) (context.Context, trace.Span) {
+	ctx = extractTraceContext(ctx, msg)
+	return c.opts.tracer.Start(ctx, c.topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", c.topic),
+			attribute.String("messaging.consumer.group.name", c.groupID),
+			attribute.String("messaging.message.id", msg.MsgID),
+		),
+	)
+}
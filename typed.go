@@ -0,0 +1,53 @@
+package redis_mq
+
+import (
+	"context"
+
+	"github.com/bing-bing-student/redis-mq/redis"
+)
+
+// TypedMsgCallback 接收到消息并解码为 T 后执行的回调函数
+type TypedMsgCallback[T any] func(ctx context.Context, msg *redis.MsgEntity, val *T) error
+
+// TypedProducer 将 T 序列化后写入消息 val 的生产者, 序列化方式由 WithProducerCodec 指定
+type TypedProducer[T any] struct {
+	producer *Producer
+}
+
+// NewTypedProducer 新建类型化的生产者
+func NewTypedProducer[T any](client ProducerClient, opts ...ProducerOption) *TypedProducer[T] {
+	return &TypedProducer[T]{
+		producer: NewProducer(client, opts...),
+	}
+}
+
+// SendMsg 将 val 序列化后生产一条消息
+func (p *TypedProducer[T]) SendMsg(ctx context.Context, topic, key string, val T) (string, error) {
+	return p.producer.SendValue(ctx, topic, key, val)
+}
+
+// Producer 返回底层的生产者
+func (p *TypedProducer[T]) Producer() *Producer {
+	return p.producer
+}
+
+// NewTypedConsumer 新建类型化的消费者, 消息 val 会被反序列化为 T 后传给回调函数, 序列化方式由 WithConsumerCodec 指定.
+// 反序列化失败的消息不会重试, 而是直接投递到死信队列
+func NewTypedConsumer[T any](client ConsumerClient, topic, groupID, consumerID string, callbackFunc TypedMsgCallback[T], opts ...ConsumerOption) (*Consumer, error) {
+	c := newConsumer(client, topic, groupID, consumerID, nil)
+	if callbackFunc != nil {
+		c.callbackFunc = func(ctx context.Context, msg *redis.MsgEntity) error {
+			val := new(T)
+			if err := c.Decode(msg, val); err != nil {
+				return err
+			}
+			return callbackFunc(ctx, msg, val)
+		}
+	}
+	if err := c.init(opts...); err != nil {
+		return nil, err
+	}
+
+	c.start()
+	return c, nil
+}